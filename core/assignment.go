@@ -1,23 +1,20 @@
 package core
 
 import (
-	"errors"
-	"strconv"
+	"reflect"
 	"strings"
 
-	"github.com/techxmind/go-utils/object"
+	"github.com/pkg/errors"
 )
 
-var _assignmentFactory AssignmentFactory
+var _assignmentFactory = newStdAssignmentFactory()
 
 func init() {
-	_assignmentFactory = &stdAssignmentFactory{
-		assignments: map[string]Assignment{
-			"=": &EqualAssignment{},
-			"+": &MergeAssignment{},
-			"-": &DeleteAssignment{},
-		},
-	}
+	_assignmentFactory.Register(&EqualAssignment{}, "=")
+	_assignmentFactory.Register(&MergeAssignment{}, "+")
+	_assignmentFactory.Register(&DeleteAssignment{}, "-")
+	// built-in assignments can not be overridden in default engine
+	_assignmentFactory.Protect("=", "+", "-")
 }
 
 type Assignment interface {
@@ -25,15 +22,30 @@ type Assignment interface {
 	PrepareValue(value interface{}) (interface{}, error)
 }
 
+// AssignmentE is optional interface of Assignment that can report error, e.g. value type mismatch
+type AssignmentE interface {
+	RunE(ctx *Context, data interface{}, key string, val interface{}) error
+}
+
 type BaseAssignmentPrepareValue struct{}
 
 func (self *BaseAssignmentPrepareValue) PrepareValue(value interface{}) (interface{}, error) {
 	return value, nil
 }
 
+// AssignmentFactory is concurrency-safe registry of assignments
 type AssignmentFactory interface {
 	Get(string) Assignment
+	// Register assignment with names, overriding protected name is logged
 	Register(Assignment, ...string)
+	// TryRegister register assignment only if none of the names is registered
+	TryRegister(Assignment, ...string) error
+	// Unregister assignment names, protected names are kept
+	Unregister(...string)
+	// Protect names of built-ins, TryRegister and Unregister deny them, Register logs overriding
+	Protect(...string)
+	// List return names of registered assignments
+	List() []string
 }
 
 // GetAssignmentFactory return AssignmentFactory for registering new Assigment
@@ -43,21 +55,61 @@ func GetAssignmentFactory() AssignmentFactory {
 }
 
 type stdAssignmentFactory struct {
-	assignments map[string]Assignment
+	registry *registry
+
+	// search assignment in parent if not found
+	parent *stdAssignmentFactory
+}
+
+func newStdAssignmentFactory() *stdAssignmentFactory {
+	return &stdAssignmentFactory{
+		registry: newRegistry("assignment"),
+	}
 }
 
 func (self *stdAssignmentFactory) Get(name string) Assignment {
-	if assignment, ok := self.assignments[name]; ok {
-		return assignment
+	if assignment, ok := self.registry.get(name); ok {
+		return assignment.(Assignment)
+	}
+
+	if self.parent != nil {
+		return self.parent.Get(name)
 	}
 
 	return nil
 }
 
 func (self *stdAssignmentFactory) Register(op Assignment, names ...string) {
-	for _, name := range names {
-		self.assignments[name] = op
+	self.registry.register(op, names)
+}
+
+func (self *stdAssignmentFactory) TryRegister(op Assignment, names ...string) error {
+	return self.registry.tryRegister(op, names, func(name string) bool {
+		return self.parent != nil && self.parent.Get(name) != nil
+	})
+}
+
+func (self *stdAssignmentFactory) Unregister(names ...string) {
+	self.registry.unregister(names)
+}
+
+func (self *stdAssignmentFactory) Protect(names ...string) {
+	self.registry.protect(names)
+}
+
+func (self *stdAssignmentFactory) List() []string {
+	var parent []string
+	if self.parent != nil {
+		parent = self.parent.List()
 	}
+
+	return self.registry.list(parent)
+}
+
+// Getter is custom data container that provides value of key path for data.xx and ctx.xx variables,
+// returns false if key is not handled, then value is looked up as usual.
+type Getter interface {
+	AssignmentGet(key string) (interface{}, bool)
 }
 
 //["key", "=", "val"]
@@ -67,46 +119,29 @@ type Setter interface {
 
 type EqualAssignment struct{ BaseAssignmentPrepareValue }
 
-func (self *EqualAssignment) Run(_ *Context, data interface{}, key string, value interface{}) {
+func (self *EqualAssignment) Run(ctx *Context, data interface{}, key string, value interface{}) {
+	if err := self.RunE(ctx, data, key, value); err != nil {
+		Logger.Printf("Assignment[%s =] err:%v\n", key, err)
+	}
+}
+
+// RunE set value of key, data can be struct pointer or typed map, value is converted to the type of target.
+func (self *EqualAssignment) RunE(_ *Context, data interface{}, key string, value interface{}) error {
 	if v, ok := data.(Setter); ok {
 		if v.AssignmentSet(key, value) {
-			return
+			return nil
 		}
 	}
 
 	keys := strings.Split(key, ".")
 	lastKey := keys[len(keys)-1]
 
-	var obj interface{}
-	if len(keys) > 1 {
-		obj, _ = object.GetObject(data, strings.Join(keys[:len(keys)-1], "."), true)
-	} else {
-		obj = data
+	obj, err := walkPath(data, keys[:len(keys)-1], true)
+	if err != nil || !obj.IsValid() {
+		return err
 	}
 
-	if obj == nil {
-		return
-	}
-
-	switch v := obj.(type) {
-	case map[string]interface{}:
-		if IsScalar(value) {
-			v[lastKey] = value
-		} else {
-			v[lastKey] = Clone(value)
-		}
-	case []interface{}:
-		if index, err := strconv.ParseInt(lastKey, 10, 32); err == nil {
-			if int(index) >= len(v) {
-				return
-			}
-			if IsScalar(value) {
-				v[int(index)] = value
-			} else {
-				v[int(index)] = Clone(value)
-			}
-		}
-	}
+	return setValue(obj, lastKey, value)
 }
 
 //["key", "+", {}]
@@ -115,44 +150,62 @@ type Merger interface {
 }
 type MergeAssignment struct{}
 
-func (self *MergeAssignment) Run(_ *Context, data interface{}, key string, value interface{}) {
+func (self *MergeAssignment) Run(ctx *Context, data interface{}, key string, value interface{}) {
+	if err := self.RunE(ctx, data, key, value); err != nil {
+		Logger.Printf("Assignment[%s +] err:%v\n", key, err)
+	}
+}
+
+// RunE merge value into object of key, object can be struct pointer or typed map.
+func (self *MergeAssignment) RunE(_ *Context, data interface{}, key string, value interface{}) error {
 	if v, ok := data.(Merger); ok {
 		if v.AssignmentMerge(key, value) {
-			return
+			return nil
 		}
 	}
 
 	keys := strings.Split(key, ".")
 	lastKey := keys[len(keys)-1]
 
-	var obj interface{}
+	obj, err := walkPath(data, keys[:len(keys)-1], true)
+	if err != nil || !obj.IsValid() {
+		return err
+	}
 
-	if len(keys) > 1 {
-		obj, _ = object.GetObject(data, strings.Join(keys[:len(keys)-1], "."), true)
-	} else {
-		obj = data
+	robj, err := childValue(obj, lastKey, false)
+	if err != nil {
+		return err
 	}
 
-	if obj == nil {
-		return
+	target := indirect(robj)
+
+	// not exists or nil
+	if !target.IsValid() {
+		return setValue(obj, lastKey, value)
 	}
 
-	switch v := obj.(type) {
-	case map[string]interface{}:
-		if robj, ok := v[lastKey]; !ok {
-			v[lastKey] = value
-		} else {
-			if robj2, ok := robj.(map[string]interface{}); ok {
-				for ikey, ivalue := range value.(map[string]interface{}) {
-					if IsScalar(ivalue) {
-						robj2[ikey] = ivalue
-					} else {
-						robj2[ikey] = Clone(ivalue)
-					}
-				}
+	if robj2, ok := target.Interface().(map[string]interface{}); ok {
+		for ikey, ivalue := range value.(map[string]interface{}) {
+			if IsScalar(ivalue) {
+				robj2[ikey] = ivalue
+			} else {
+				robj2[ikey] = Clone(ivalue)
 			}
 		}
+		return nil
+	}
+
+	if target.Kind() != reflect.Map && target.Kind() != reflect.Struct {
+		return errors.Errorf("Can not merge into key[%s] of %s", key, target.Type())
+	}
+
+	for ikey, ivalue := range value.(map[string]interface{}) {
+		if err := setValue(robj, ikey, ivalue); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
 
 func (self *MergeAssignment) PrepareValue(value interface{}) (interface{}, error) {
@@ -160,6 +213,9 @@ func (self *MergeAssignment) PrepareValue(value interface{}) (interface{}, error
 		return nil, errors.New("assignment[+] value must not be nil")
 	}
 
+	// map[interface{}]interface{} from yaml
+	value = Normalize(value)
+
 	if _, ok := value.(map[string]interface{}); !ok {
 		return nil, errors.New("assignment[+] value must be map[string]interface{}")
 	}
@@ -173,7 +229,14 @@ type Deleter interface {
 }
 type DeleteAssignment struct{}
 
-func (self *DeleteAssignment) Run(_ *Context, data interface{}, key string, value interface{}) {
+func (self *DeleteAssignment) Run(ctx *Context, data interface{}, key string, value interface{}) {
+	if err := self.RunE(ctx, data, key, value); err != nil {
+		Logger.Printf("Assignment[%s -] err:%v\n", key, err)
+	}
+}
+
+// RunE delete keys from object of key, struct fields are set to zero value.
+func (self *DeleteAssignment) RunE(_ *Context, data interface{}, key string, value interface{}) error {
 	// can use '$' or '.' to specify root path
 	// ["$", "-", "key1,key2.."]
 	// [".", "-", "key1,key2.."]
@@ -181,23 +244,27 @@ func (self *DeleteAssignment) Run(_ *Context, data interface{}, key string, valu
 
 	if v, ok := data.(Deleter); ok {
 		if v.AssignmentDelete(key, value) {
-			return
+			return nil
 		}
 	}
 
-	obj, _ := object.GetObject(data, key, false)
+	var keys []string
+	if key != "" {
+		keys = strings.Split(key, ".")
+	}
 
-	if obj == nil {
-		return
+	obj, err := walkPath(data, keys, false)
+	if err != nil || !obj.IsValid() {
+		return err
 	}
 
-	if v, ok := obj.(map[string]interface{}); ok {
-		for _, key := range value.([]interface{}) {
-			if _, ok := v[key.(string)]; ok {
-				delete(v, key.(string))
-			}
+	for _, name := range value.([]interface{}) {
+		if err := deleteValue(obj, name.(string)); err != nil {
+			return err
 		}
 	}
+
+	return nil
 }
 
 func (self *DeleteAssignment) PrepareValue(value interface{}) (interface{}, error) {
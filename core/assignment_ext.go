@@ -2,7 +2,6 @@ package core
 
 import (
 	"math"
-	"math/rand"
 
 	"github.com/pkg/errors"
 
@@ -12,6 +11,7 @@ import (
 func init() {
 	_assignmentFactory.Register(&GroupAssign{}, "=>")
 	_assignmentFactory.Register(&ProbabilitySet{}, "*=")
+	_assignmentFactory.Protect("=>", "*=")
 }
 
 // ProbabilitySet set value with specified probability.
@@ -19,7 +19,18 @@ func init() {
 //  ["key", "*=", [ [10, "value1"], [10, "value2"], [10, "value3"] ]]
 //  value1,value2,value3 has the same weight 10, each of them has a probability 10/(10+10+10) = 1/3 to been chosen.
 //
+// Choice can be sticky to value of variable, e.g. the same user always gets the same value:
+//  ["key", "*=", {"by": "ctx.uid", "salt": "exp1", "items": [ [10, "value1"], [10, "value2"] ]}]
+//  salt is optional, default is key.
+//
 type ProbabilitySet struct {
+	// engine that provides "=" assignment and variables, bound by the engine that compiles executor
+	engine *Engine
+}
+
+// withEngine implements engineAssignment
+func (a *ProbabilitySet) withEngine(e *Engine) Assignment {
+	return &ProbabilitySet{engine: e}
 }
 
 type probabilityItem struct {
@@ -27,7 +38,18 @@ type probabilityItem struct {
 	value     interface{}
 }
 
+type probabilitySticky struct {
+	variable Variable
+	salt     string
+	hasSalt  bool
+	items    []*probabilityItem
+}
+
 func (a *ProbabilitySet) PrepareValue(value interface{}) (val interface{}, err error) {
+	if m, ok := value.(map[string]interface{}); ok {
+		return a.prepareSticky(m)
+	}
+
 	if !IsArray(value) {
 		err = errors.Errorf("assignment[*=] value must be array.")
 		return
@@ -35,7 +57,7 @@ func (a *ProbabilitySet) PrepareValue(value interface{}) (val interface{}, err e
 
 	var (
 		linePoint int64 = 0
-		setter          = _assignmentFactory.Get("=")
+		setter          = getEngine(a.engine).assignmentFactory.Get("=")
 		items           = make([]*probabilityItem, 0)
 	)
 
@@ -78,35 +100,107 @@ func (a *ProbabilitySet) PrepareValue(value interface{}) (val interface{}, err e
 	return items, nil
 }
 
+func (a *ProbabilitySet) prepareSticky(m map[string]interface{}) (interface{}, error) {
+	sticky := &probabilitySticky{}
+
+	for key, value := range m {
+		switch key {
+		case "by":
+			name, ok := value.(string)
+			if !ok {
+				return nil, errors.Errorf("assignment[*=] by must be variable name.")
+			}
+			if sticky.variable = getEngine(a.engine).variableFactory.Create(name); sticky.variable == nil {
+				return nil, errors.Errorf("assignment[*=] unknown variable[%s].", name)
+			}
+		case "salt":
+			salt, ok := value.(string)
+			if !ok {
+				return nil, errors.Errorf("assignment[*=] salt must be string.")
+			}
+			sticky.salt, sticky.hasSalt = salt, true
+		case "items":
+			items, err := a.PrepareValue(value)
+			if err != nil {
+				return nil, err
+			}
+			if sticky.items, _ = items.([]*probabilityItem); sticky.items == nil {
+				return nil, errors.Errorf("assignment[*=] items must be array.")
+			}
+		default:
+			return nil, errors.Errorf("assignment[*=] unknown option[%s].", key)
+		}
+	}
+
+	if sticky.variable == nil {
+		return nil, errors.Errorf("assignment[*=] by is required.")
+	}
+
+	return sticky, nil
+}
+
 func (a *ProbabilitySet) Run(ctx *Context, data interface{}, key string, value interface{}) {
-	items, ok := value.([]*probabilityItem)
-	if !ok {
+	var (
+		items []*probabilityItem
+		r     Rand
+	)
+
+	switch v := value.(type) {
+	case []*probabilityItem:
+		items, r = v, ctx.Rand()
+	case *probabilitySticky:
+		salt := key
+		if v.hasSalt {
+			salt = v.salt
+		}
+		items, r = v.items, StickyRand(ctx, v.variable, salt)
+	default:
 		return
 	}
+
 	n := len(items)
 	if n == 0 {
 		return
 	}
 	max := items[n-1].linePoint
-	choose := rand.Intn(int(max)) + 1
+	if max <= 0 {
+		return
+	}
+	choose := r.Int63n(max) + 1
 	for _, item := range items {
-		if choose <= int(item.linePoint) {
-			_assignmentFactory.Get("=").Run(ctx, data, key, item.value)
+		if choose <= item.linePoint {
+			getEngine(a.engine).assignmentFactory.Get("=").Run(ctx, data, key, item.value)
 			break
 		}
 	}
 }
 
-type GroupAssign struct{}
+// GroupAssign run multiple executors.
+// e.g. :
+//  ["set", "=>", [ ["key1", "=", "value1"], ["key2", "+", {"foo":"bar"}] ]]
+//
+type GroupAssign struct {
+	// engine that compiles executors, bound by the engine that compiles executor
+	engine *Engine
+}
+
+// withEngine implements engineAssignment
+func (a *GroupAssign) withEngine(e *Engine) Assignment {
+	return &GroupAssign{engine: e}
+}
+
+// nested implements nestedAssignment, executors in value interpolate their own values
+func (a *GroupAssign) nested() {}
 
 func (a *GroupAssign) PrepareValue(value interface{}) (val interface{}, err error) {
 	if !IsArray(value) {
 		return nil, errors.New("assignment[=>] value must be array")
 	}
 
-	executor, err := NewExecutor(ToArray(value))
-	if err != nil {
-		return nil, errors.Wrap(err, "assignment[=>]")
+	errs := make(ValidationErrors, 0)
+	executor := getEngine(a.engine).compileExecutor(ToArray(value), "", &errs)
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
 	return executor, nil
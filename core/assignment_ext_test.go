@@ -55,3 +55,37 @@ func TestGroupAssign(t *testing.T) {
 	a.Run(ctx, data, "set", val2)
 	assert.Equal(t, map[string]interface{}{"a": "a", "b": "b", "c": "c"}, data)
 }
+
+func TestProbabilitySetSticky(t *testing.T) {
+	a := _assignmentFactory.Get("*=")
+	items := []interface{}{
+		[]interface{}{50, "a"},
+		[]interface{}{50, "b"},
+	}
+
+	_, err := a.PrepareValue(map[string]interface{}{"items": items})
+	assert.Error(t, err, "by is required")
+	_, err = a.PrepareValue(map[string]interface{}{"by": "unknown", "items": items})
+	assert.Error(t, err)
+	_, err = a.PrepareValue(map[string]interface{}{"by": "ctx.uid", "items": items, "foo": 1})
+	assert.Error(t, err)
+
+	val, err := a.PrepareValue(map[string]interface{}{"by": "ctx.uid", "salt": "exp1", "items": items})
+	require.NoError(t, err)
+
+	hit := make(map[string]int)
+	for uid := 0; uid < 1000; uid++ {
+		ctx := NewContext()
+		ctx.Set("uid", uid)
+		data := make(map[string]interface{})
+		a.Run(ctx, data, "v", val)
+		first := data["v"]
+		for i := 0; i < 5; i++ {
+			a.Run(ctx, data, "v", val)
+			require.Equal(t, first, data["v"], "uid %d", uid)
+		}
+		hit[first.(string)]++
+	}
+	assert.Equal(t, 2, len(hit))
+	assert.True(t, hit["a"] > 400 && hit["b"] > 400, "hit:%v", hit)
+}
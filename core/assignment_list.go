@@ -0,0 +1,190 @@
+package core
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+
+	"github.com/techxmind/go-utils/compare"
+	"github.com/techxmind/go-utils/itype"
+)
+
+func init() {
+	for _, op := range []string{"append", "prepend", "insert", "remove", "unique"} {
+		_assignmentFactory.Register(&ListAssignment{op: op}, op)
+	}
+}
+
+// ListAssignment change list of key, the list is replaced by a new one, so it's never changed in place.
+// Value that is not a list is the only element, wrap list element in a list, e.g. [[1, 2]].
+// e.g. :
+//  ["banners", "append", [{"id": 3}, {"id": 4}]]   // missing key is empty list
+//  ["banners", "prepend", {"id": 0}]               // missing key is empty list
+//  ["banners", "insert", [1, {"id": 5}]]           // insert elements at index, [index, elements...]
+//  ["banners", "remove", [{"id": 3}, {"id": 4}]]   // remove elements equal to value
+//  ["banners", "unique", "id"]                     // remove duplicates by key of element, null to compare whole element
+//
+type ListAssignment struct {
+	op string
+}
+
+func (a *ListAssignment) Run(ctx *Context, data interface{}, key string, value interface{}) {
+	if err := a.RunE(ctx, data, key, value); err != nil {
+		Logger.Printf("Assignment[%s %s] err:%v\n", key, a.op, err)
+	}
+}
+
+func (a *ListAssignment) RunE(ctx *Context, data interface{}, key string, value interface{}) error {
+	current, ok := GetValue(data, key)
+	if (!ok || current == nil) && (a.op == "remove" || a.op == "unique") {
+		return nil
+	}
+
+	list, err := toList(current)
+	if err != nil {
+		return errors.Wrapf(err, "Value of key[%s]", key)
+	}
+
+	switch a.op {
+	case "append":
+		list = append(list, cloneList(value.([]interface{}))...)
+	case "prepend":
+		list = append(cloneList(value.([]interface{})), list...)
+	case "insert":
+		args := value.([]interface{})
+		index := int(itype.Int(args[0]))
+		if index > len(list) {
+			index = len(list)
+		}
+		list = append(append(append([]interface{}{}, list[:index]...), cloneList(args[1:])...), list[index:]...)
+	case "remove":
+		list = removeElements(list, value.([]interface{}))
+	case "unique":
+		list = uniqueElements(list, value)
+	}
+
+	return (&EqualAssignment{}).RunE(ctx, data, key, list)
+}
+
+func (a *ListAssignment) PrepareValue(value interface{}) (interface{}, error) {
+	// map[interface{}]interface{} from yaml
+	value = Normalize(value)
+
+	switch a.op {
+	case "unique":
+		if _, ok := value.(string); value != nil && !ok {
+			return nil, errors.New("assignment[unique] value must be key of element or null")
+		}
+		return value, nil
+	case "insert":
+		args, ok := value.([]interface{})
+		if !ok || len(args) < 2 {
+			return nil, errors.New("assignment[insert] value must be [index, elements...]")
+		}
+		if itype.GetType(args[0]) != itype.NUMBER || itype.Float(args[0]) < 0 || itype.Float(args[0]) != float64(itype.Int(args[0])) {
+			return nil, errors.New("assignment[insert] index must be non-negative integer")
+		}
+		return args, nil
+	}
+
+	if list, ok := value.([]interface{}); ok {
+		return list, nil
+	}
+
+	return []interface{}{value}, nil
+}
+
+// toList convert slice or array to []interface{}, nil is empty list
+func toList(v interface{}) ([]interface{}, error) {
+	if v == nil {
+		return []interface{}{}, nil
+	}
+
+	if list, ok := v.([]interface{}); ok {
+		return append([]interface{}(nil), list...), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, errors.Errorf("%T is not list", v)
+	}
+
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = interfaceValue(rv.Index(i))
+	}
+
+	return list, nil
+}
+
+// cloneList copy elements, prepared value is shared by every run
+func cloneList(list []interface{}) []interface{} {
+	elements := make([]interface{}, len(list))
+	for i, elem := range list {
+		if IsScalar(elem) {
+			elements[i] = elem
+		} else {
+			elements[i] = Clone(elem)
+		}
+	}
+
+	return elements
+}
+
+func removeElements(list, values []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(list))
+
+	for _, elem := range list {
+		removed := false
+		for _, value := range values {
+			if equalElement(elem, value) {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			result = append(result, elem)
+		}
+	}
+
+	return result
+}
+
+func uniqueElements(list []interface{}, key interface{}) []interface{} {
+	result := make([]interface{}, 0, len(list))
+	seen := make([]interface{}, 0, len(list))
+
+	for _, elem := range list {
+		id := elem
+		if k, ok := key.(string); ok && k != "" {
+			// elements without key are kept
+			if id, ok = GetValue(elem, k); !ok {
+				result = append(result, elem)
+				continue
+			}
+		}
+
+		duplicated := false
+		for _, s := range seen {
+			if equalElement(s, id) {
+				duplicated = true
+				break
+			}
+		}
+		if !duplicated {
+			seen = append(seen, id)
+			result = append(result, elem)
+		}
+	}
+
+	return result
+}
+
+// equalElement compare scalars like = operation, others deeply
+func equalElement(a, b interface{}) bool {
+	if IsScalar(a) && IsScalar(b) {
+		return compare.Object(a, b) == 0
+	}
+
+	return reflect.DeepEqual(Normalize(a), Normalize(b))
+}
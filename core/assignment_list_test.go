@@ -0,0 +1,92 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAssignment(t *testing.T) {
+	ctx := NewContext()
+	banners := []interface{}{
+		map[string]interface{}{"id": 1},
+		map[string]interface{}{"id": 2},
+	}
+	data := map[string]interface{}{
+		"banners": banners,
+		"tags":    []interface{}{"a", "b", "a", "c"},
+	}
+
+	run := func(item []interface{}) {
+		executor, err := NewExecutor(item)
+		require.NoError(t, err, "%v", item)
+		executor.Execute(ctx, data)
+	}
+	ids := func() []interface{} {
+		ids := make([]interface{}, 0)
+		for _, b := range data["banners"].([]interface{}) {
+			ids = append(ids, b.(map[string]interface{})["id"])
+		}
+		return ids
+	}
+
+	run(_e("banners", "append", []interface{}{map[string]interface{}{"id": 3}, map[string]interface{}{"id": 4}}))
+	assert.Equal(t, []interface{}{1, 2, 3, 4}, ids())
+	assert.Len(t, banners, 2, "list is not changed in place")
+
+	run(_e("banners", "prepend", map[string]interface{}{"id": 0}))
+	assert.Equal(t, []interface{}{0, 1, 2, 3, 4}, ids())
+
+	run(_e("banners", "insert", []interface{}{2, map[string]interface{}{"id": 5}, map[string]interface{}{"id": 6}}))
+	assert.Equal(t, []interface{}{0, 1, 5, 6, 2, 3, 4}, ids())
+	run(_e("banners", "insert", []interface{}{100, map[string]interface{}{"id": 2}}))
+	assert.Equal(t, []interface{}{0, 1, 5, 6, 2, 3, 4, 2}, ids())
+
+	run(_e("banners", "unique", "id"))
+	assert.Equal(t, []interface{}{0, 1, 5, 6, 2, 3, 4}, ids())
+
+	run(_e("banners", "remove", []interface{}{map[string]interface{}{"id": 5}, map[string]interface{}{"id": 6}}))
+	assert.Equal(t, []interface{}{0, 1, 2, 3, 4}, ids())
+
+	run(_e("tags", "unique", nil))
+	assert.Equal(t, []interface{}{"a", "b", "c"}, data["tags"])
+	run(_e("tags", "remove", "b"))
+	assert.Equal(t, []interface{}{"a", "c"}, data["tags"])
+	run(_e("tags", "append", []interface{}{[]interface{}{"x", "y"}}))
+	assert.Equal(t, []interface{}{"a", "c", []interface{}{"x", "y"}}, data["tags"])
+
+	run(_e("new", "append", 1))
+	assert.Equal(t, []interface{}{1}, data["new"], "missing key is empty list")
+	run(_e("none", "remove", 1))
+	_, ok := data["none"]
+	assert.False(t, ok)
+
+	// appended elements are copies of value
+	executor, err := NewExecutor(_e("list", "append", map[string]interface{}{"a": 1}))
+	require.NoError(t, err)
+	d1, d2 := make(map[string]interface{}), make(map[string]interface{})
+	executor.Execute(ctx, d1)
+	executor.Execute(ctx, d2)
+	d1["list"].([]interface{})[0].(map[string]interface{})["a"] = 2
+	assert.Equal(t, 1, d2["list"].([]interface{})[0].(map[string]interface{})["a"])
+
+	// typed slice of struct
+	obj := &struct {
+		Tags []string `json:"tags"`
+	}{Tags: []string{"a"}}
+	executor, err = NewExecutor(_e("tags", "prepend", []interface{}{"x", "y"}))
+	require.NoError(t, err)
+	executor.Execute(ctx, obj)
+	assert.Equal(t, []string{"x", "y", "a"}, obj.Tags)
+
+	for _, item := range [][]interface{}{
+		_e("a", "insert", []interface{}{1}),
+		_e("a", "insert", []interface{}{-1, 1}),
+		_e("a", "insert", []interface{}{1.5, 1}),
+		_e("a", "unique", 1),
+	} {
+		_, err := NewExecutor(item)
+		assert.Error(t, err, "%v", item)
+	}
+}
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/pkg/errors"
+
+	"github.com/techxmind/go-utils/itype"
+)
+
+func init() {
+	_assignmentFactory.Register(&ArithmeticAssignment{op: "+="}, "+=")
+	_assignmentFactory.Register(&ArithmeticAssignment{op: "-="}, "-=")
+	_assignmentFactory.Register(&ArithmeticAssignment{op: "x="}, "x=")
+	_assignmentFactory.Register(&ClampAssignment{}, "clamp")
+}
+
+// ArithmeticAssignment change number of key.
+// e.g. :
+//  ["price", "+=", 10]   // add, missing key is 0
+//  ["price", "-=", 10]   // subtract, missing key is 0
+//  ["price", "x=", 0.8]  // scale, missing key is skipped
+// Result keeps integer type of the number if it's exact, otherwise it's float64.
+// Result that overflows the integer type is an error, the number is not changed, e.g. int8(100) += 100.
+//
+type ArithmeticAssignment struct {
+	op string
+}
+
+func (a *ArithmeticAssignment) Run(ctx *Context, data interface{}, key string, value interface{}) {
+	if err := a.RunE(ctx, data, key, value); err != nil {
+		Logger.Printf("Assignment[%s %s] err:%v\n", key, a.op, err)
+	}
+}
+
+func (a *ArithmeticAssignment) RunE(ctx *Context, data interface{}, key string, value interface{}) error {
+	current, ok := GetValue(data, key)
+	if !ok || current == nil {
+		if a.op == "x=" {
+			return nil
+		}
+		current = 0
+	}
+
+	if itype.GetType(current) != itype.NUMBER {
+		return errors.Errorf("Value of key[%s] is not number", key)
+	}
+
+	var result float64
+	switch a.op {
+	case "+=":
+		result = itype.Float(current) + itype.Float(value)
+	case "-=":
+		result = itype.Float(current) - itype.Float(value)
+	default:
+		result = itype.Float(current) * itype.Float(value)
+	}
+
+	number, err := numberResult(current, result)
+	if err != nil {
+		return errors.Wrapf(err, "Value of key[%s]", key)
+	}
+
+	return (&EqualAssignment{}).RunE(ctx, data, key, number)
+}
+
+func (a *ArithmeticAssignment) PrepareValue(value interface{}) (interface{}, error) {
+	if itype.GetType(value) != itype.NUMBER {
+		return nil, errors.Errorf("assignment[%s] value must be number", a.op)
+	}
+
+	return value, nil
+}
+
+// ClampAssignment limit number of key in range [min, max], missing key is skipped.
+// e.g. :
+//  ["price", "clamp", [10, 100]]
+//  ["price", "clamp", [null, 100]]  // no lower bound
+//
+type ClampAssignment struct{}
+
+func (a *ClampAssignment) Run(ctx *Context, data interface{}, key string, value interface{}) {
+	if err := a.RunE(ctx, data, key, value); err != nil {
+		Logger.Printf("Assignment[%s clamp] err:%v\n", key, err)
+	}
+}
+
+func (a *ClampAssignment) RunE(ctx *Context, data interface{}, key string, value interface{}) error {
+	current, ok := GetValue(data, key)
+	if !ok || current == nil {
+		return nil
+	}
+
+	if itype.GetType(current) != itype.NUMBER {
+		return errors.Errorf("Value of key[%s] is not number", key)
+	}
+
+	bounds := value.([]interface{})
+	n := itype.Float(current)
+	var bound interface{}
+	if bounds[0] != nil && n < itype.Float(bounds[0]) {
+		bound = bounds[0]
+	} else if bounds[1] != nil && n > itype.Float(bounds[1]) {
+		bound = bounds[1]
+	} else {
+		return nil
+	}
+
+	number, err := numberResult(current, itype.Float(bound))
+	if err != nil {
+		return errors.Wrapf(err, "Value of key[%s]", key)
+	}
+
+	return (&EqualAssignment{}).RunE(ctx, data, key, number)
+}
+
+func (a *ClampAssignment) PrepareValue(value interface{}) (interface{}, error) {
+	bounds := ToArray(value)
+	if len(bounds) != 2 || (bounds[0] == nil && bounds[1] == nil) {
+		return nil, errors.New("assignment[clamp] value must be [min, max]")
+	}
+
+	for _, bound := range bounds {
+		if bound != nil && itype.GetType(bound) != itype.NUMBER {
+			return nil, errors.New("assignment[clamp] min and max must be number or null")
+		}
+	}
+
+	if bounds[0] != nil && bounds[1] != nil && itype.Float(bounds[0]) > itype.Float(bounds[1]) {
+		return nil, errors.New("assignment[clamp] min must not be greater than max")
+	}
+
+	return bounds, nil
+}
+
+// numberResult return result as integer of the type of current if current is integer and result is exact.
+// Return error if result overflows the type, e.g. int8(100) + 100 or uint8(3) - 5.
+func numberResult(current interface{}, result float64) (interface{}, error) {
+	if !isInteger(current) || result != math.Trunc(result) {
+		return result, nil
+	}
+
+	v := reflect.New(reflect.TypeOf(current)).Elem()
+	if isUnsigned(v.Kind()) {
+		if result < 0 || result >= 1<<64 || v.OverflowUint(uint64(result)) {
+			return nil, errors.Errorf("%v overflows %T", result, current)
+		}
+		v.SetUint(uint64(result))
+	} else {
+		if result < math.MinInt64 || result >= 1<<63 || v.OverflowInt(int64(result)) {
+			return nil, errors.Errorf("%v overflows %T", result, current)
+		}
+		v.SetInt(int64(result))
+	}
+
+	return v.Interface(), nil
+}
+
+func isInteger(v interface{}) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+
+	return isUnsigned(reflect.ValueOf(v).Kind())
+}
+
+func isUnsigned(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+
+	return false
+}
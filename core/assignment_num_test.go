@@ -0,0 +1,100 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArithmeticAssignment(t *testing.T) {
+	ctx := NewContext()
+	data := map[string]interface{}{
+		"price":    100,
+		"discount": 2.5,
+		"name":     "foo",
+	}
+
+	run := func(item []interface{}) {
+		executor, err := NewExecutor(item)
+		require.NoError(t, err, "%v", item)
+		executor.Execute(ctx, data)
+	}
+
+	run(_e("price", "+=", 10))
+	assert.Equal(t, 110, data["price"])
+	run(_e("price", "-=", 20))
+	assert.Equal(t, 90, data["price"])
+	run(_e("price", "x=", 0.5))
+	assert.Equal(t, 45, data["price"], "exact result keeps integer type")
+	run(_e("price", "x=", 0.5))
+	assert.Equal(t, 22.5, data["price"])
+	run(_e("discount", "-=", 0.5))
+	assert.Equal(t, 2.0, data["discount"])
+
+	run(_e("count", "+=", 1))
+	assert.Equal(t, 1, data["count"], "missing key is 0")
+	run(_e("none", "x=", 2))
+	_, ok := data["none"]
+	assert.False(t, ok, "missing key is skipped")
+
+	run(_e("price", "clamp", []interface{}{30, 100}))
+	assert.Equal(t, 30.0, data["price"])
+	run(_e("price", "clamp", []interface{}{nil, 10}))
+	assert.Equal(t, 10.0, data["price"])
+	run(_e("count", "clamp", []interface{}{5, nil}))
+	assert.Equal(t, 5, data["count"])
+
+	// value type mismatch is reported
+	collector := NewErrorCollector(ERROR_AS_FALSE)
+	executor, err := NewExecutor(_e("name", "+=", 1))
+	require.NoError(t, err)
+	executor.Execute(WithContext(ctx, WithErrorCollector(collector)), data)
+	assert.Equal(t, 1, collector.Len())
+	assert.Equal(t, "foo", data["name"])
+
+	// struct fields
+	obj := &struct {
+		Price uint `json:"price"`
+	}{Price: 10}
+	executor, err = NewExecutor(_e("price", "+=", 5))
+	require.NoError(t, err)
+	executor.Execute(ctx, obj)
+	assert.Equal(t, uint(15), obj.Price)
+
+	// overflow of integer type is reported, the number is not changed
+	for _, c := range []struct {
+		value    interface{}
+		executor []interface{}
+	}{
+		{int8(100), _e("n", "+=", 100)},
+		{uint8(3), _e("n", "-=", 5)},
+		{uint8(200), _e("n", "x=", 2)},
+		{int8(1), _e("n", "clamp", []interface{}{200, nil})},
+	} {
+		collector := NewErrorCollector(ERROR_AS_FALSE)
+		data := map[string]interface{}{"n": c.value}
+		executor, err := NewExecutor(c.executor)
+		require.NoError(t, err)
+		executor.Execute(WithContext(ctx, WithErrorCollector(collector)), data)
+		assert.Equal(t, 1, collector.Len(), "%v %v", c.value, c.executor)
+		assert.Equal(t, c.value, data["n"], "%v %v", c.value, c.executor)
+	}
+	data = map[string]interface{}{"n": uint8(3)}
+	run(_e("n", "-=", 3))
+	assert.Equal(t, uint8(0), data["n"])
+	run(_e("n", "+=", 255))
+	assert.Equal(t, uint8(255), data["n"])
+
+	for _, item := range [][]interface{}{
+		_e("a", "+=", "1"),
+		_e("a", "x=", nil),
+		_e("a", "clamp", 1),
+		_e("a", "clamp", []interface{}{nil, nil}),
+		_e("a", "clamp", []interface{}{10, 1}),
+		_e("a", "clamp", []interface{}{"a", 1}),
+	} {
+		_, err := NewExecutor(item)
+		assert.Error(t, err, "%v", item)
+	}
+}
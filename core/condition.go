@@ -3,8 +3,7 @@ package core
 import (
 	"fmt"
 	"strings"
-
-	"github.com/pkg/errors"
+	"time"
 )
 
 //Condition interface
@@ -19,30 +18,129 @@ type StdCondition struct {
 	variable  Variable
 	operation Operation
 	value     interface{}
+	// value contains variable references, nil if value is literal
+	operand *operand
+
+	// original definition: [key, operation name, raw value]
+	key           string
+	operationName string
+	rawValue      interface{}
 }
 
-func (c *StdCondition) Success(ctx *Context) bool {
-	ok := c.operation.Run(ctx, c.variable, c.value)
+func (c *StdCondition) Success(ctx *Context) (ok bool) {
+	if observer := ctx.Observer(); observer != nil {
+		start := time.Now()
+		defer func() {
+			observer.ObserveCondition(c.expr, ok, time.Since(start))
+		}()
+	}
+
+	// conditions evaluated by variables are children of the condition, e.g. @is_vip
+	recorder := ctx.Recorder()
+	if recorder != nil {
+		recorder.enterScope()
+	}
+
+	// resolve value first, so the error of variable is known and the recorded value is exactly the one operation compares with
+	value, err := GetVariableValueE(ctx, c.variable)
+	if err == nil {
+		ok, err = c.run(ctx, &resolvedVariable{c.variable, value})
+	}
+
+	var children []*ConditionResult
+	if recorder != nil {
+		children = recorder.leaveScope()
+	}
+
+	if err != nil {
+		reportError(ctx, &EvalError{
+			Expr:     c.expr,
+			Variable: c.key,
+			Err:      err,
+		})
+		ok = false
+	}
+
+	if recorder != nil {
+		recorder.addCondition(&ConditionResult{
+			Expr:       c.expr,
+			Variable:   c.key,
+			Value:      value,
+			Operation:  c.operationName,
+			Operand:    c.rawValue,
+			Succ:       ok,
+			Conditions: children,
+		})
+	}
 
 	if trace := ctx.Trace(); trace != nil {
-		trace.Log(
-			c.String(),
-			" => ",
-			GetVariableValue(ctx, c.variable),
-			c.operation.String(),
-			c.value,
-			" => ",
-			ok,
-		)
+		trace.Log(c.String(), " => ", value, c.operation.String(), c.value, " => ", ok)
 	}
 
 	return ok
 }
 
+func (c *StdCondition) run(ctx *Context, variable Variable) (bool, error) {
+	value := c.value
+	if c.operand != nil {
+		raw, err := c.operand.resolve(ctx, false)
+		if err != nil {
+			return false, err
+		}
+		if value, err = c.operation.PrepareValue(raw); err != nil {
+			return false, err
+		}
+	}
+
+	if op, ok := c.operation.(OperationE); ok {
+		return op.RunE(ctx, variable, value)
+	}
+
+	return c.operation.Run(ctx, variable, value), nil
+}
+
+// Variable return variable of condition
+func (c *StdCondition) Variable() Variable {
+	return c.variable
+}
+
+// OperandVariables return variables referenced by operation value
+func (c *StdCondition) OperandVariables() []Variable {
+	if c.operand == nil {
+		return nil
+	}
+
+	return c.operand.variables
+}
+
+// compileVariable create variable of key, key with prefix "@" is result of named condition.
+// item is definition that contains key, for error message.
+func (e *Engine) compileVariable(key string, path string, item interface{}, errs *ValidationErrors) Variable {
+	if strings.HasPrefix(key, CONDITION_VARIABLE_PREFIX) {
+		// result of named condition: ["@$name", "=", true]
+		if named := e.conditionResolver().resolve(key[len(CONDITION_VARIABLE_PREFIX):], path, errs); named != nil {
+			return &conditionVariable{named}
+		}
+		return nil
+	}
+
+	variable := e.variableFactory.Create(key)
+	if variable == nil {
+		errs.Add(path, key, ERR_UNKNOWN_VARIABLE, "Unknown var[%s]. -> %s", key, jstr(item))
+	}
+
+	return variable
+}
+
 func (c *StdCondition) String() string {
 	return c.expr
 }
 
+// MarshalJSON output the canonical array form of condition definition: ["$var-name", "$op", "$op-value"]
+func (c *StdCondition) MarshalJSON() ([]byte, error) {
+	return MarshalDefinition([]interface{}{c.key, c.operationName, c.rawValue})
+}
+
 //ConditionGroup
 type GROUP_LOGIC int
 
@@ -58,10 +156,42 @@ type ConditionGroup struct {
 	conditions []Condition
 }
 
-func (c *ConditionGroup) Success(ctx *Context) bool {
-	result := c.logic != LOGIC_ANY_NOT
+func (c *ConditionGroup) Success(ctx *Context) (result bool) {
+	if recorder := ctx.Recorder(); recorder != nil {
+		r := &ConditionResult{
+			Expr:  c.String(),
+			Logic: c.LogicKey(),
+		}
+		recorder.enterGroup(r)
+		defer func() {
+			r.Succ = result
+			recorder.leaveGroup()
+		}()
+	}
+
+	errs := ctx.ErrorCollector()
+	errCount := errs.Len()
+
+	result = c.logic != LOGIC_ANY_NOT
 	for _, condition := range c.conditions {
-		if ok := condition.Success(ctx); ok {
+		// stop evaluation when ctx is canceled or deadline exceeded
+		if ctx.Interrupted() {
+			if trace := ctx.Trace(); trace != nil {
+				trace.Log("INTERRUPTED", ctx.Err())
+			}
+			result = false
+			break
+		}
+
+		ok := condition.Success(ctx)
+
+		// error fails the whole filter by policy
+		if errs.Failed(errCount, ERROR_FAIL_FILTER) {
+			result = false
+			break
+		}
+
+		if ok {
 			if c.logic == LOGIC_ANY {
 				result = true
 				break
@@ -88,12 +218,7 @@ func (c *ConditionGroup) Success(ctx *Context) bool {
 
 func (c *ConditionGroup) String() string {
 	var b strings.Builder
-	for k, v := range groupConditionKeys {
-		if v == c.logic {
-			b.WriteString(strings.ToUpper(k))
-			break
-		}
-	}
+	b.WriteString(strings.ToUpper(c.LogicKey()))
 	b.WriteString("{")
 	for _, c := range c.conditions {
 		b.WriteString(c.String())
@@ -104,6 +229,37 @@ func (c *ConditionGroup) String() string {
 	return b.String()
 }
 
+// MarshalJSON output: ["$logic", "=>", [$conditions...]]
+func (c *ConditionGroup) MarshalJSON() ([]byte, error) {
+	conditions := make([]interface{}, len(c.conditions))
+	for i, condition := range c.conditions {
+		conditions[i] = Definition(condition)
+	}
+
+	return MarshalDefinition([]interface{}{c.LogicKey(), "=>", conditions})
+}
+
+// Logic return group logic
+func (c *ConditionGroup) Logic() GROUP_LOGIC {
+	return c.logic
+}
+
+// LogicKey return group logic key in definition, e.g. any?
+func (c *ConditionGroup) LogicKey() string {
+	for k, v := range groupConditionKeys {
+		if v == c.logic {
+			return k
+		}
+	}
+
+	return ""
+}
+
+// Conditions return sub conditions of group
+func (c *ConditionGroup) Conditions() []Condition {
+	return c.conditions
+}
+
 func (c *ConditionGroup) add(condition Condition) {
 	c.conditions = append(c.conditions, condition)
 }
@@ -123,68 +279,108 @@ var groupConditionKeys = map[string]GROUP_LOGIC{
 }
 
 func NewCondition(item []interface{}, groupLogic GROUP_LOGIC) (Condition, error) {
+	return _defaultEngine.NewCondition(item, groupLogic)
+}
+
+// compileCondition build condition, record all problems of definition in errs.
+// path is location of item in the whole definition.
+func (e *Engine) compileCondition(item []interface{}, groupLogic GROUP_LOGIC, path string, errs *ValidationErrors) Condition {
 	if len(item) == 0 {
-		return nil, errors.New("Empty")
+		errs.Add(path, item, ERR_EMPTY, "Empty")
+		return nil
 	}
 
 	if IsArray(item[0]) {
 		group := NewConditionGroup(groupLogic)
 
-		for _, subitem := range item {
+		for i, subitem := range item {
 			if !IsArray(subitem) {
-				return nil, errors.Errorf("Sub item must be an array. -> %s", jstr(subitem))
+				errs.Add(indexPath(path, i), subitem, ERR_NOT_ARRAY, "Sub item must be an array. -> %s", jstr(subitem))
+				continue
 			}
-			if subCondition, err := NewCondition(ToArray(subitem), LOGIC_ALL); err != nil {
-				return nil, err
-			} else {
+			if subCondition := e.compileCondition(ToArray(subitem), LOGIC_ALL, indexPath(path, i), errs); subCondition != nil {
 				group.add(subCondition)
 			}
 		}
 
-		return group, nil
+		return group
 	}
 
 	if len(item) != 3 {
-		return nil, errors.Errorf("Item must contains 3 elements. -> %s", jstr(item))
+		errs.Add(path, item, ERR_ELEMENT_COUNT, "Item must contains 3 elements. -> %s", jstr(item))
+		return nil
 	}
 
 	key, ok := item[0].(string)
 	if !ok {
-		return nil, errors.Errorf("Item 1st element[%g] is not string. -> %s", item[0], jstr(item))
+		errs.Add(indexPath(path, 0), item[0], ERR_NOT_STRING, "Item 1st element[%v] is not string. -> %s", item[0], jstr(item))
+		return nil
+	}
+
+	// reference of named condition: ["use?", "=", "$name"]
+	if key == CONDITION_REF_KEY {
+		name, ok := item[2].(string)
+		if op, _ := item[1].(string); op != "=" || !ok {
+			errs.Add(path, item, ERR_INVALID_VALUE, "Condition reference must be [\"%s\", \"=\", \"$name\"]. -> %s", CONDITION_REF_KEY, jstr(item))
+			return nil
+		}
+		if named := e.conditionResolver().resolve(name, indexPath(path, 2), errs); named != nil {
+			return &conditionRef{named}
+		}
+		return nil
 	}
 
 	if logic, ok := groupConditionKeys[key]; ok {
 		list, ok := item[2].([]interface{})
 		if !ok {
-			return nil, errors.Errorf("Logic[%s] item 3rd element must be an array. -> %s", key, jstr(item))
+			errs.Add(indexPath(path, 2), item[2], ERR_NOT_ARRAY, "Logic[%s] item 3rd element must be an array. -> %s", key, jstr(item))
+			return nil
 		}
-		if group, err := NewCondition(list, logic); err != nil {
-			return nil, err
-		} else {
-			return group, nil
-		}
-	}
 
-	variable := _variableFactory.Create(key)
-	if variable == nil {
-		return nil, errors.Errorf("Unknown var[%s]. -> %s", key, jstr(item))
+		return e.compileCondition(list, logic, indexPath(path, 2), errs)
 	}
 
+	variable := e.compileVariable(key, indexPath(path, 0), item, errs)
+
 	operationName, ok := item[1].(string)
 	if !ok {
-		return nil, errors.Errorf("Item 2nd element(operation) is not string. -> %s", jstr(item))
+		errs.Add(indexPath(path, 1), item[1], ERR_NOT_STRING, "Item 2nd element(operation) is not string. -> %s", jstr(item))
+		return nil
 	}
 
-	operation := _operationFactory.Get(operationName)
+	operation := e.operationFactory.Get(operationName)
 
 	if operation == nil {
-		return nil, errors.Errorf("Unknown operation[%s]. -> %s", operationName, jstr(item))
+		errs.Add(indexPath(path, 1), operationName, ERR_UNKNOWN_OPERATION, "Unknown operation[%s]. -> %s", operationName, jstr(item))
+		return nil
+	}
+
+	n := len(*errs)
+	operand, _ := e.compileOperand(item[2], false, indexPath(path, 2), errs)
+	if len(*errs) > n {
+		return nil
 	}
 
-	pvalue, err := operation.PrepareValue(item[2])
+	// value with variable references is prepared when condition runs,
+	// shape of list value is checked now with nil in place of variables
+	pvalue := item[2]
+	if value, ok := operand.placeholder(item[2]); ok {
+		prepared, err := operation.PrepareValue(value)
+		if operand == nil {
+			pvalue = prepared
+		}
 
-	if err != nil {
-		return nil, err
+		if verrs, ok := err.(ValidationErrors); ok {
+			*errs = append(*errs, verrs.Prefix(indexPath(path, 2))...)
+			return nil
+		} else if err != nil {
+			errs.Add(indexPath(path, 2), item[2], ERR_INVALID_VALUE, "%s", err)
+			return nil
+		}
+	}
+
+	if variable == nil {
+		return nil
 	}
 
 	expr := fmt.Sprintf("%s %s %s", key, operationName, jstr(pvalue))
@@ -194,7 +390,12 @@ func NewCondition(item []interface{}, groupLogic GROUP_LOGIC) (Condition, error)
 		variable:  variable,
 		operation: operation,
 		value:     pvalue,
+		operand:   operand,
+
+		key:           key,
+		operationName: operationName,
+		rawValue:      item[2],
 	}
 
-	return condition, nil
+	return condition
 }
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -132,3 +133,115 @@ func TestCondition(t *testing.T) {
 		assert.Equal(t, c.expected, cond.Success(ctx), "case %d: %s", i, cond.String())
 	}
 }
+
+func TestConditionMarshalJSON(t *testing.T) {
+	tests := []struct {
+		item     []interface{}
+		logic    GROUP_LOGIC
+		expected string
+	}{
+		{
+			item:     []interface{}{"ctx.a.b", "~", "/^a/"},
+			logic:    LOGIC_ALL,
+			expected: `["ctx.a.b","~","/^a/"]`,
+		},
+		{
+			item: []interface{}{
+				[]interface{}{"ctx.a.b", "=", 1},
+				[]interface{}{"not?", "=>", []interface{}{
+					[]interface{}{"ctx.a.c", "in", "1,2"},
+				}},
+			},
+			logic:    LOGIC_ANY,
+			expected: `["any?","=>",[["ctx.a.b","=",1],["not?","=>",[["ctx.a.c","in","1,2"]]]]]`,
+		},
+	}
+
+	for i, c := range tests {
+		cond, err := NewCondition(c.item, c.logic)
+		require.NoError(t, err)
+		b, err := json.Marshal(cond)
+		require.NoError(t, err)
+		assert.JSONEq(t, c.expected, string(b), "case %d", i)
+
+		var item []interface{}
+		require.NoError(t, json.Unmarshal(b, &item))
+		cond2, err := NewCondition(item, LOGIC_ALL)
+		require.NoError(t, err)
+		assert.Equal(t, cond.String(), cond2.String(), "case %d", i)
+	}
+}
+
+func TestVariableReference(t *testing.T) {
+	ref := func(name string) map[string]interface{} {
+		return map[string]interface{}{"$var": name}
+	}
+	ctx := WithData(NewContext(), map[string]interface{}{
+		"price":          80,
+		"original_price": 100,
+		"min_price":      50,
+		"city":           "beijing",
+		"cities":         []interface{}{"beijing", "shanghai"},
+		"pattern":        "/^bei/",
+	})
+	ctx.Set("city", "beijing")
+
+	tests := []struct {
+		item     []interface{}
+		expected bool
+	}{
+		{[]interface{}{"data.price", "<", ref("data.original_price")}, true},
+		{[]interface{}{"data.price", ">=", ref("data.original_price")}, false},
+		{[]interface{}{"data.city", "=", ref("ctx.city")}, true},
+		{[]interface{}{"data.city", "!=", ref("ctx.city")}, false},
+		{[]interface{}{"data.price", "between", []interface{}{ref("data.min_price"), ref("data.original_price")}}, true},
+		{[]interface{}{"data.price", "between", []interface{}{ref("data.min_price"), 60}}, false},
+		{[]interface{}{"ctx.city", "in", []interface{}{"guangzhou", ref("data.city")}}, true},
+		{[]interface{}{"ctx.city", "in", ref("data.cities")}, true},
+		{[]interface{}{"ctx.city", "not in", ref("data.cities")}, false},
+		{[]interface{}{"data.city", "~", ref("data.pattern")}, true},
+		{[]interface{}{"data.price", "<", ref("data.none")}, false},
+	}
+
+	for _, c := range tests {
+		condition, err := NewCondition(c.item, LOGIC_ALL)
+		require.NoError(t, err, "%v", c.item)
+		assert.Equal(t, c.expected, condition.Success(ctx), "%v", c.item)
+	}
+
+	condition, err := NewCondition([]interface{}{"data.price", "between", []interface{}{ref("data.min_price"), ref("ctx.max")}}, LOGIC_ALL)
+	require.NoError(t, err)
+	names := make([]string, 0)
+	for _, v := range ConditionVariables(condition) {
+		names = append(names, v.Name())
+	}
+	assert.Equal(t, []string{"data.price", "data.min_price", "ctx.max"}, names)
+
+	b, err := json.Marshal(condition)
+	require.NoError(t, err)
+	assert.Equal(t, `["data.price","between",[{"$var":"data.min_price"},{"$var":"ctx.max"}]]`, string(b))
+
+	// invalid value prepared at run time is reported as error
+	condition, err = NewCondition([]interface{}{"data.price", "between", ref("data.min_price")}, LOGIC_ALL)
+	require.NoError(t, err)
+	collector := NewErrorCollector(ERROR_AS_FALSE)
+	assert.False(t, condition.Success(WithContext(ctx, WithErrorCollector(collector))))
+	assert.Equal(t, 1, collector.Len())
+
+	// shape of list value with variable references is checked at compile time
+	_, err = NewCondition([]interface{}{"data.price", "between", []interface{}{ref("data.min_price")}}, LOGIC_ALL)
+	assert.Error(t, err)
+	errs := ValidateCondition([]interface{}{"data.price", "between", []interface{}{ref("data.min_price")}}, LOGIC_ALL)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "[2]", errs[0].Path)
+	assert.Equal(t, ERR_INVALID_VALUE, errs[0].Code)
+
+	errs = ValidateCondition([]interface{}{
+		[]interface{}{"data.price", "<", ref("unknown")},
+		[]interface{}{"data.price", "in", []interface{}{1, map[string]interface{}{"$var": 1}}},
+	}, LOGIC_ALL)
+	require.Len(t, errs, 2)
+	assert.Equal(t, "[0][2].$var", errs[0].Path)
+	assert.Equal(t, ERR_UNKNOWN_VARIABLE, errs[0].Code)
+	assert.Equal(t, "[1][2][1].$var", errs[1].Path)
+}
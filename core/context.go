@@ -14,6 +14,12 @@ const (
 	cacheCtxKey      ctxKey = "cache"
 	ctxDataCtxKey    ctxKey = "ctx"
 	traceCtxKey      ctxKey = "trace"
+	recorderCtxKey   ctxKey = "recorder"
+	observerCtxKey   ctxKey = "observer"
+	errorsCtxKey     ctxKey = "errors"
+	cowCtxKey        ctxKey = "cow"
+	randCtxKey       ctxKey = "rand"
+	strictCtxKey     ctxKey = "strict_template"
 )
 
 type Context struct {
@@ -49,6 +55,51 @@ func WithTrace(trace Trace) ContextOption {
 	})
 }
 
+// WithRecorder ContextOption, record structured evaluation details of conditions and executors
+func WithRecorder(recorder *Recorder) ContextOption {
+	return ContextOptionFunc(func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, recorderCtxKey, recorder)
+	})
+}
+
+// WithObserver ContextOption, observer receives evaluation metrics
+func WithObserver(observer Observer) ContextOption {
+	return ContextOptionFunc(func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, observerCtxKey, observer)
+	})
+}
+
+// WithErrorCollector ContextOption, collector receives errors of variables and operations
+func WithErrorCollector(collector *ErrorCollector) ContextOption {
+	return ContextOptionFunc(func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, errorsCtxKey, collector)
+	})
+}
+
+// WithCopyOnWrite ContextOption, executors change copy of data, get the new root with cow.Root()
+func WithCopyOnWrite(cow *CopyOnWrite) ContextOption {
+	return ContextOptionFunc(func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, cowCtxKey, cow)
+	})
+}
+
+// WithRand ContextOption, source of random numbers for ranking and probability choices,
+// e.g. rand.New(rand.NewSource(1)) makes them reproducible in tests.
+// Filters may run concurrently with the same Context, use a concurrency-safe Rand in that case.
+func WithRand(r Rand) ContextOption {
+	return ContextOptionFunc(func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, randCtxKey, r)
+	})
+}
+
+// WithStrictTemplate ContextOption, executor fails if variable in its value template has no value,
+// otherwise the variable is nil, or "" in string template.
+func WithStrictTemplate() ContextOption {
+	return ContextOptionFunc(func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, strictCtxKey, true)
+	})
+}
+
 func WithContext(ctx context.Context, opts ...ContextOption) *Context {
 	if ctx == nil {
 		ctx = context.Background()
@@ -72,10 +123,10 @@ func WithContext(ctx context.Context, opts ...ContextOption) *Context {
 
 // WithData return new *Context contains data.
 // call every time the filter runs, to make data thread-safe
-func WithData(ctx context.Context, data interface{}) *Context {
+func WithData(ctx context.Context, data interface{}, opts ...ContextOption) *Context {
 	ctx = context.WithValue(ctx, filterDataCtxKey, data)
 
-	return WithContext(ctx)
+	return WithContext(ctx, opts...)
 }
 
 // Data return filter data
@@ -120,6 +171,58 @@ func (c *Context) Trace() Trace {
 	return nil
 }
 
+// Recorder return Recorder
+func (c *Context) Recorder() *Recorder {
+	if r := c.ctx.Value(recorderCtxKey); r != nil {
+		return r.(*Recorder)
+	}
+
+	return nil
+}
+
+// Observer return Observer
+func (c *Context) Observer() Observer {
+	if o := c.ctx.Value(observerCtxKey); o != nil {
+		return o.(Observer)
+	}
+
+	return nil
+}
+
+// ErrorCollector return ErrorCollector
+func (c *Context) ErrorCollector() *ErrorCollector {
+	if e := c.ctx.Value(errorsCtxKey); e != nil {
+		return e.(*ErrorCollector)
+	}
+
+	return nil
+}
+
+// CopyOnWrite return CopyOnWrite
+func (c *Context) CopyOnWrite() *CopyOnWrite {
+	if cow := c.ctx.Value(cowCtxKey); cow != nil {
+		return cow.(*CopyOnWrite)
+	}
+
+	return nil
+}
+
+// Rand return Rand set by WithRand, or the one uses global source of math/rand
+func (c *Context) Rand() Rand {
+	if r := c.ctx.Value(randCtxKey); r != nil {
+		return r.(Rand)
+	}
+
+	return globalRand{}
+}
+
+// StrictTemplate report whether executor fails on variable without value in its value template
+func (c *Context) StrictTemplate() bool {
+	strict, _ := c.ctx.Value(strictCtxKey).(bool)
+
+	return strict
+}
+
 // Set set context data
 func (c *Context) Set(key string, value interface{}) {
 	if data := c.ctx.Value(ctxDataCtxKey); data != nil {
@@ -169,6 +272,34 @@ func (c *Context) Value(key interface{}) interface{} {
 	return c.ctx.Value(key)
 }
 
+// Interrupted report whether evaluation should stop, ctx is canceled or deadline exceeded
+func (c *Context) Interrupted() bool {
+	return c.ctx.Err() != nil
+}
+
+// detach return Context shares values with c, but is never canceled.
+// Used when evaluation can not stop halfway, e.g. applying executors.
+func (c *Context) detach() *Context {
+	// make sure cache is shared
+	c.Cache()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return &Context{
+		ctx: detachedContext{c.ctx},
+	}
+}
+
+// detachedContext is context.Context without cancellation and deadline
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) { return }
+func (detachedContext) Done() <-chan struct{}                   { return nil }
+func (detachedContext) Err() error                              { return nil }
+
 // contextData
 type contextData struct {
 	mu sync.Mutex
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewContext(t *testing.T) {
@@ -57,3 +58,64 @@ func Benchmark_Context(b *testing.B) {
 		}
 	})
 }
+
+type cancelAssignment struct {
+	BaseAssignmentPrepareValue
+	cancel func()
+}
+
+func (a *cancelAssignment) Run(_ *Context, data interface{}, key string, value interface{}) {
+	data.(map[string]interface{})[key] = value
+	a.cancel()
+}
+
+func TestInterrupt(t *testing.T) {
+	pctx, cancel := context.WithCancel(context.Background())
+	ctx := WithContext(pctx)
+	assert.False(t, ctx.Interrupted())
+
+	// cancel while evaluating the first condition
+	calls := 0
+	e := NewEngine(InheritDefault())
+	e.VariableFactory().Register(SingletonVariableCreator(NewSimpleVariable("cancel", Uncacheable, ValueFunc(func(_ *Context) interface{} {
+		calls++
+		cancel()
+		return 1
+	}))), "cancel")
+
+	cond, err := e.NewCondition([]interface{}{
+		[]interface{}{"cancel", "=", 1},
+		[]interface{}{"cancel", "=", 1},
+	}, LOGIC_ALL)
+	require.NoError(t, err)
+	assert.False(t, cond.Success(ctx))
+	assert.Equal(t, 1, calls)
+	assert.True(t, ctx.Interrupted())
+
+	// none of executors are applied
+	executor, err := NewExecutor([]interface{}{
+		[]interface{}{"a", "=", 1},
+		[]interface{}{"b", "=", 1},
+	})
+	require.NoError(t, err)
+	data := make(map[string]interface{})
+	executor.Execute(ctx, data)
+	assert.Empty(t, data)
+
+	// executors started are not interrupted halfway
+	pctx, cancel = context.WithCancel(context.Background())
+	ctx = WithContext(pctx)
+	e.AssignmentFactory().Register(&cancelAssignment{cancel: cancel}, "cancel=")
+	executor, err = e.NewExecutor([]interface{}{
+		[]interface{}{"a", "cancel=", 1},
+		[]interface{}{"set", "=>", []interface{}{
+			[]interface{}{"b", "=", 1},
+			[]interface{}{"c", "=", 1},
+		}},
+	})
+	require.NoError(t, err)
+	data = make(map[string]interface{})
+	executor.Execute(ctx, data)
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 1, "c": 1}, data)
+	assert.True(t, ctx.Interrupted())
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CopyOnWrite makes executors change a copy of data, set it with context option WithCopyOnWrite.
+// Before executor runs, containers on the path of its key are copied shallowly once,
+// so the original data is never changed and untouched parts are shared with the new root.
+// Only map[string]interface{} and []interface{} are copied, it's not concurrency-safe.
+type CopyOnWrite struct {
+	root   interface{}
+	copied map[uintptr]bool
+}
+
+func NewCopyOnWrite(root interface{}) *CopyOnWrite {
+	return &CopyOnWrite{
+		root:   root,
+		copied: make(map[uintptr]bool),
+	}
+}
+
+// Root return current root, it's the original data if nothing is changed
+func (c *CopyOnWrite) Root() interface{} {
+	return c.root
+}
+
+// prepare copy containers on the path of key, return new root
+func (c *CopyOnWrite) prepare(key string) interface{} {
+	c.root = c.copy(c.root)
+
+	// '$' or '.' is root path of DeleteAssignment
+	key = strings.TrimLeft(strings.TrimLeft(key, "$"), ".")
+	if key == "" {
+		return c.root
+	}
+
+	parent := c.root
+	for _, segment := range strings.Split(key, ".") {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			child, ok := p[segment]
+			if !ok {
+				return c.root
+			}
+			child = c.copy(child)
+			p[segment] = child
+			parent = child
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(p) {
+				return c.root
+			}
+			p[index] = c.copy(p[index])
+			parent = p[index]
+		default:
+			return c.root
+		}
+	}
+
+	return c.root
+}
+
+// copy return shallow copy of container if it's not copied yet
+func (c *CopyOnWrite) copy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if c.copied[reflect.ValueOf(v).Pointer()] {
+			return v
+		}
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = value
+		}
+		c.copied[reflect.ValueOf(m).Pointer()] = true
+		return m
+	case []interface{}:
+		if len(v) > 0 && c.copied[reflect.ValueOf(v).Pointer()] {
+			return v
+		}
+		l := make([]interface{}, len(v))
+		copy(l, v)
+		if len(l) > 0 {
+			c.copied[reflect.ValueOf(l).Pointer()] = true
+		}
+		return l
+	}
+
+	return v
+}
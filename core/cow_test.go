@@ -0,0 +1,58 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyOnWrite(t *testing.T) {
+	shared := map[string]interface{}{"x": 1}
+	base := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{"c": 1},
+			"d": shared,
+		},
+		"l":      []interface{}{map[string]interface{}{"e": 1}, 2},
+		"merge":  map[string]interface{}{"m": 1},
+		"delete": map[string]interface{}{"k": 1, "v": 1},
+	}
+	origin := Clone(base)
+
+	executor, err := NewExecutor([]interface{}{
+		[]interface{}{"a.b.c", "=", 2},
+		[]interface{}{"a.b.new", "=", 1},
+		[]interface{}{"l.0.e", "=", 2},
+		[]interface{}{"merge", "+", map[string]interface{}{"n": 1}},
+		[]interface{}{"delete", "-", []interface{}{"k"}},
+		[]interface{}{"set", "=>", []interface{}{
+			[]interface{}{"new.f", "=", 1},
+			[]interface{}{"top", "=", 1},
+		}},
+	})
+	require.NoError(t, err)
+
+	cow := NewCopyOnWrite(base)
+	assert.Equal(t, base, cow.Root())
+
+	ctx := WithContext(NewContext(), WithCopyOnWrite(cow))
+	executor.Execute(ctx, base)
+
+	assert.Equal(t, origin, base, "base data is not changed")
+	assert.Equal(t, map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{"c": 2, "new": 1},
+			"d": shared,
+		},
+		"l":      []interface{}{map[string]interface{}{"e": 2}, 2},
+		"merge":  map[string]interface{}{"m": 1, "n": 1},
+		"delete": map[string]interface{}{"v": 1},
+		"new":    map[string]interface{}{"f": 1},
+		"top":    1,
+	}, cow.Root())
+
+	// untouched parts are shared
+	shared["y"] = 1
+	assert.Equal(t, 1, cow.Root().(map[string]interface{})["a"].(map[string]interface{})["d"].(map[string]interface{})["y"])
+}
@@ -0,0 +1,166 @@
+package core
+
+// Engine owns variable, operation and assignment registries,
+// conditions and executors are compiled with the registered items of engine.
+// Package level functions such as NewCondition, NewExecutor, GetVariableFactory
+// work with the default engine.
+type Engine struct {
+	variableFactory   *stdVariableFactory
+	operationFactory  *stdOperationFactory
+	assignmentFactory *stdAssignmentFactory
+	library           *ConditionLibrary
+
+	// resolver of named conditions while compiling, library if nil
+	resolver conditionResolver
+}
+
+var _defaultEngine = &Engine{
+	variableFactory:   _variableFactory,
+	operationFactory:  _operationFactory,
+	assignmentFactory: _assignmentFactory,
+}
+
+func init() {
+	_defaultEngine.library = newConditionLibrary(_defaultEngine)
+}
+
+// DefaultEngine return the default engine, which is populated by core and ext packages.
+func DefaultEngine() *Engine {
+	return _defaultEngine
+}
+
+type EngineOption interface {
+	apply(*Engine)
+}
+
+type EngineOptionFunc func(*Engine)
+
+func (f EngineOptionFunc) apply(e *Engine) {
+	f(e)
+}
+
+// InheritDefault EngineOption, items not registered in engine will be searched in default engine.
+// Items registered to default engine later are also visible.
+func InheritDefault() EngineOption {
+	return EngineOptionFunc(func(e *Engine) {
+		e.variableFactory.parent = _defaultEngine.variableFactory
+		e.operationFactory.parent = _defaultEngine.operationFactory
+		e.assignmentFactory.parent = _defaultEngine.assignmentFactory
+		e.library.parent = _defaultEngine.library
+	})
+}
+
+// NewEngine create engine with empty registries.
+//  e.g.
+//    // engine contains default variables, operations and assignments, with custom = operation
+//    engine := NewEngine(InheritDefault())
+//    engine.OperationFactory().Register(&MyEqualOperation{}, "=")
+//
+func NewEngine(opts ...EngineOption) *Engine {
+	e := &Engine{
+		variableFactory:   newStdVariableFactory(),
+		operationFactory:  newStdOperationFactory(),
+		assignmentFactory: newStdAssignmentFactory(),
+	}
+	e.library = newConditionLibrary(e)
+
+	for _, opt := range opts {
+		opt.apply(e)
+	}
+
+	return e
+}
+
+// VariableFactory return VariableFactory of engine
+func (e *Engine) VariableFactory() VariableFactory {
+	return e.variableFactory
+}
+
+// OperationFactory return OperationFactory of engine
+func (e *Engine) OperationFactory() OperationFactory {
+	return e.operationFactory
+}
+
+// AssignmentFactory return AssignmentFactory of engine
+func (e *Engine) AssignmentFactory() AssignmentFactory {
+	return e.assignmentFactory
+}
+
+// ConditionLibrary return ConditionLibrary of engine
+func (e *Engine) ConditionLibrary() *ConditionLibrary {
+	return e.library
+}
+
+// conditionResolver return resolver of named conditions
+func (e *Engine) conditionResolver() conditionResolver {
+	if e.resolver != nil {
+		return e.resolver
+	}
+
+	return e.library
+}
+
+// NewCondition build condition with registered items of engine. See NewCondition.
+func (e *Engine) NewCondition(item []interface{}, groupLogic GROUP_LOGIC) (Condition, error) {
+	errs := make(ValidationErrors, 0)
+	condition := e.compileCondition(item, groupLogic, "", &errs)
+
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	return condition, nil
+}
+
+// NewExecutor build executor with registered items of engine. See NewExecutor.
+func (e *Engine) NewExecutor(item []interface{}) (Executor, error) {
+	errs := make(ValidationErrors, 0)
+	executor := e.compileExecutor(item, "", &errs)
+
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	return executor, nil
+}
+
+// ValidateCondition check condition definition with registered items of engine. See ValidateCondition.
+func (e *Engine) ValidateCondition(item []interface{}, groupLogic GROUP_LOGIC) ValidationErrors {
+	errs := make(ValidationErrors, 0)
+	e.compileCondition(item, groupLogic, "", &errs)
+
+	return errs
+}
+
+// ValidateExecutor check executor definition with registered items of engine. See ValidateExecutor.
+func (e *Engine) ValidateExecutor(item []interface{}) ValidationErrors {
+	errs := make(ValidationErrors, 0)
+	e.compileExecutor(item, "", &errs)
+
+	return errs
+}
+
+// ParseCondition build condition from expression with registered items of engine. See ParseCondition.
+func (e *Engine) ParseCondition(expr string) (Condition, error) {
+	item, err := ParseConditionData(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.NewCondition(item, LOGIC_ALL)
+}
+
+// engineAssignment is assignment that compiles or runs other items of engine, e.g. => and *=.
+// It's bound to the engine that compiles executor, so registered instance works in any engine.
+type engineAssignment interface {
+	withEngine(e *Engine) Assignment
+}
+
+// getEngine return default engine if e is nil
+func getEngine(e *Engine) *Engine {
+	if e == nil {
+		return _defaultEngine
+	}
+
+	return e
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type alwaysOperation struct {
+	stringer
+	operationBase
+}
+
+func (o *alwaysOperation) Run(_ *Context, _ Variable, _ interface{}) bool { return true }
+
+type upperAssignment struct{ BaseAssignmentPrepareValue }
+
+func (a *upperAssignment) Run(_ *Context, data interface{}, key string, _ interface{}) {
+	data.(map[string]interface{})[key] = "UPPER"
+}
+
+func TestEngine(t *testing.T) {
+	ctx := NewContext()
+
+	e := NewEngine()
+	_, err := e.NewCondition([]interface{}{"succ", "=", true}, LOGIC_ALL)
+	assert.Error(t, err, "empty engine")
+
+	e.VariableFactory().Register(SingletonVariableCreator(NewSimpleVariable("foo", Cacheable, &StaticValue{Val: 1})), "foo")
+	e.OperationFactory().Register(&EqualOperation{stringer: stringer("=")}, "=")
+	c, err := e.NewCondition([]interface{}{"foo", "=", 1}, LOGIC_ALL)
+	require.NoError(t, err)
+	assert.True(t, c.Success(ctx))
+	assert.Nil(t, GetVariableFactory().Create("foo"), "default engine is not affected")
+
+	e = NewEngine(InheritDefault())
+	e.OperationFactory().Register(&alwaysOperation{stringer: stringer("=")}, "=")
+	e.AssignmentFactory().Register(&upperAssignment{}, "=")
+
+	c, err = e.NewCondition([]interface{}{"succ", "=", false}, LOGIC_ALL)
+	require.NoError(t, err)
+	assert.True(t, c.Success(ctx), "custom = operation")
+
+	c, err = NewCondition([]interface{}{"succ", "=", false}, LOGIC_ALL)
+	require.NoError(t, err)
+	assert.False(t, c.Success(ctx), "default = operation")
+
+	ex, err := e.NewExecutor([]interface{}{"set", "=>", []interface{}{"a", "=", "a"}})
+	require.NoError(t, err)
+	data := make(map[string]interface{})
+	ex.Execute(ctx, data)
+	assert.Equal(t, "UPPER", data["a"], "nested executor is compiled by engine")
+
+	ex, err = e.NewExecutor([]interface{}{"b", "*=", []interface{}{[]interface{}{1, "b"}}})
+	require.NoError(t, err)
+	ex.Execute(ctx, data)
+	assert.Equal(t, "UPPER", data["b"], "probability set with engine's = assignment")
+
+	// engine without defaults, => and *= are registered by user
+	e = NewEngine()
+	e.AssignmentFactory().Register(&upperAssignment{}, "=")
+	e.AssignmentFactory().Register(&GroupAssign{}, "=>")
+	e.AssignmentFactory().Register(&ProbabilitySet{}, "*=")
+	e.VariableFactory().Register(SingletonVariableCreator(NewSimpleVariable("uid", Cacheable, &StaticValue{Val: 1})), "uid")
+
+	data = make(map[string]interface{})
+	ex, err = e.NewExecutor([]interface{}{
+		[]interface{}{"set", "=>", []interface{}{"a", "=", "a"}},
+		[]interface{}{"b", "*=", map[string]interface{}{"by": "uid", "items": []interface{}{[]interface{}{1, "b"}}}},
+	})
+	require.NoError(t, err)
+	ex.Execute(ctx, data)
+	assert.Equal(t, map[string]interface{}{"a": "UPPER", "b": "UPPER"}, data, "=> and *= are bound to engine")
+
+	_, err = e.NewExecutor([]interface{}{"set", "=>", []interface{}{"a", "+", "a"}})
+	assert.Error(t, err, "+ is not registered in engine")
+}
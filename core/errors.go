@@ -0,0 +1,146 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ValuerE is optional interface of Variable(or Valuer of SimpleVariable) that can report error,
+// e.g. lookup of remote data source failed.
+type ValuerE interface {
+	ValueE(*Context) (interface{}, error)
+}
+
+// OperationE is optional interface of Operation that can report error
+type OperationE interface {
+	RunE(ctx *Context, variable Variable, value interface{}) (bool, error)
+}
+
+// ERROR_POLICY decides how evaluation goes on when variable or operation reports error
+type ERROR_POLICY int
+
+const (
+	// condition with error is false, evaluation goes on
+	ERROR_AS_FALSE ERROR_POLICY = iota
+	// filter with error fails without running executors, filter group goes on with next filter
+	ERROR_FAIL_FILTER
+	// filter group with error stops and fails, so do the enclosing groups.
+	// executors of filters that already succeeded are not rolled back.
+	ERROR_ABORT_GROUP
+)
+
+// EvalError is error reported by variable or operation while evaluating condition,
+// or by assignment while running executor.
+type EvalError struct {
+	Expr     string // condition or executor expression
+	Variable string // variable name, or key of executor
+	Executor bool   // reported by executor
+	Err      error
+}
+
+func (e *EvalError) Error() string {
+	if e.Executor {
+		return fmt.Sprintf("Executor[%s] err:%v", e.Expr, e.Err)
+	}
+
+	return fmt.Sprintf("Condition[%s] err:%v", e.Expr, e.Err)
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+func (e *EvalError) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"expr":     e.Expr,
+		"variable": e.Variable,
+		"error":    fmt.Sprint(e.Err),
+	}
+	if e.Executor {
+		m["executor"] = true
+	}
+
+	return json.Marshal(m)
+}
+
+// ErrorCollector collects evaluation errors of a run, set it with context option WithErrorCollector.
+// Without collector, errors are logged with Logger and conditions are false.
+type ErrorCollector struct {
+	mu     sync.Mutex
+	policy ERROR_POLICY
+	errs   []*EvalError
+}
+
+func NewErrorCollector(policy ERROR_POLICY) *ErrorCollector {
+	return &ErrorCollector{
+		policy: policy,
+	}
+}
+
+// Policy return error policy
+func (c *ErrorCollector) Policy() ERROR_POLICY {
+	return c.policy
+}
+
+// Errors return collected errors in order
+func (c *ErrorCollector) Errors() []*EvalError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*EvalError(nil), c.errs...)
+}
+
+// Len return count of collected errors, nil collector has no errors
+func (c *ErrorCollector) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.errs)
+}
+
+// Failed report whether errors collected after the first n errors make the scope fail,
+// the scope is filter for ERROR_FAIL_FILTER, filter group for ERROR_ABORT_GROUP.
+func (c *ErrorCollector) Failed(n int, scope ERROR_POLICY) bool {
+	return c != nil && c.policy >= scope && c.Len() > n
+}
+
+// Since return errors collected after the first n errors
+func (c *ErrorCollector) Since(n int) []*EvalError {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n >= len(c.errs) {
+		return nil
+	}
+
+	return append([]*EvalError(nil), c.errs[n:]...)
+}
+
+func (c *ErrorCollector) add(err *EvalError) {
+	c.mu.Lock()
+	c.errs = append(c.errs, err)
+	c.mu.Unlock()
+}
+
+// reportError send error to collector of context, or log it if there's no collector
+func reportError(ctx *Context, err *EvalError) {
+	if trace := ctx.Trace(); trace != nil {
+		trace.Log("ERR", err)
+	}
+
+	if c := ctx.ErrorCollector(); c != nil {
+		c.add(err)
+		return
+	}
+
+	Logger.Printf("%v\n", err)
+}
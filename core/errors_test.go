@@ -0,0 +1,90 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type errorValue struct{ err error }
+
+func (v errorValue) Value(_ *Context) interface{} { return nil }
+func (v errorValue) ValueE(_ *Context) (interface{}, error) {
+	return nil, v.err
+}
+
+type errorOperation struct {
+	stringer
+	operationBase
+}
+
+func (o *errorOperation) Run(_ *Context, _ Variable, _ interface{}) bool { return true }
+func (o *errorOperation) RunE(_ *Context, _ Variable, _ interface{}) (bool, error) {
+	return true, errors.New("op err")
+}
+
+func TestEvalError(t *testing.T) {
+	e := NewEngine(InheritDefault())
+	e.VariableFactory().Register(
+		SingletonVariableCreator(NewSimpleVariable("broken", Cacheable, errorValue{errors.New("lookup err")})),
+		"broken",
+	)
+	e.OperationFactory().Register(&errorOperation{stringer: stringer("err")}, "err")
+
+	newCondition := func(item ...interface{}) Condition {
+		c, err := e.NewCondition(item, LOGIC_ALL)
+		require.NoError(t, err)
+		return c
+	}
+
+	// without collector, condition with error is false
+	assert.False(t, newCondition("broken", "!=", "x").Success(NewContext()))
+	assert.False(t, newCondition("succ", "err", 1).Success(NewContext()))
+
+	tests := []struct {
+		policy   ERROR_POLICY
+		logic    string
+		expected bool
+		errCount int
+	}{
+		{ERROR_AS_FALSE, "any?", true, 1},
+		{ERROR_AS_FALSE, "none?", false, 1},
+		{ERROR_FAIL_FILTER, "any?", false, 1},
+		{ERROR_FAIL_FILTER, "none?", false, 1},
+		{ERROR_ABORT_GROUP, "not?", false, 1},
+	}
+
+	for i, c := range tests {
+		collector := NewErrorCollector(c.policy)
+		ctx := WithContext(NewContext(), WithErrorCollector(collector))
+		cond := newCondition(c.logic, "=>", []interface{}{
+			[]interface{}{"broken", "=", "x"},
+			[]interface{}{"succ", "=", true},
+		})
+		assert.Equal(t, c.expected, cond.Success(ctx), "case %d", i)
+		require.Len(t, collector.Errors(), c.errCount, "case %d", i)
+		assert.Equal(t, "broken", collector.Errors()[0].Variable)
+		assert.EqualError(t, collector.Errors()[0], `Condition[broken = "x"] err:lookup err`)
+	}
+
+	collector := NewErrorCollector(ERROR_AS_FALSE)
+	ctx := WithContext(NewContext(), WithErrorCollector(collector))
+	assert.False(t, newCondition("succ", "err", 1).Success(ctx))
+	assert.Equal(t, 1, collector.Len())
+	assert.Empty(t, collector.Since(1))
+	assert.True(t, collector.Failed(0, ERROR_AS_FALSE))
+	assert.False(t, collector.Failed(0, ERROR_FAIL_FILTER))
+
+	b, err := json.Marshal(collector.Errors()[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"expr":"succ err 1","variable":"succ","error":"op err"}`, string(b))
+
+	// value with error is not cached
+	_, err = GetVariableValueE(ctx, e.VariableFactory().Create("broken"))
+	assert.Error(t, err)
+	_, cached := ctx.Cache().Load("broken")
+	assert.False(t, cached)
+}
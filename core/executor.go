@@ -2,8 +2,6 @@ package core
 
 import (
 	"fmt"
-
-	"github.com/pkg/errors"
 )
 
 //Executor
@@ -11,12 +9,23 @@ type Executor interface {
 	Execute(*Context, interface{})
 }
 
+// nestedAssignment is implemented by assignments whose value contains executors, e.g. =>
+type nestedAssignment interface {
+	nested()
+}
+
 //StdExecutor
 type StdExecutor struct {
 	expr       string
 	key        string
 	assignment Assignment
 	value      interface{}
+	// value contains templates or variable references, nil if value is literal
+	template *operand
+
+	// original definition: [key, assignment name, raw value]
+	assignmentName string
+	rawValue       interface{}
 }
 
 func (e *StdExecutor) Execute(ctx *Context, data interface{}) {
@@ -26,7 +35,61 @@ func (e *StdExecutor) Execute(ctx *Context, data interface{}) {
 		)
 	}
 
-	e.assignment.Run(ctx, data, e.key, e.value)
+	if recorder := ctx.Recorder(); recorder != nil {
+		recorder.addExecutor(&ExecutorResult{
+			Expr:       e.expr,
+			Key:        e.key,
+			Assignment: e.assignmentName,
+			Value:      e.rawValue,
+		})
+	}
+
+	// change copy of data, the root may be replaced
+	if cow := ctx.CopyOnWrite(); cow != nil {
+		data = cow.prepare(e.key)
+	}
+
+	value := e.value
+	if e.template != nil {
+		var err error
+		if value, err = e.interpolate(ctx); err != nil {
+			e.reportError(ctx, err)
+			return
+		}
+	}
+
+	if assignment, ok := e.assignment.(AssignmentE); ok {
+		if err := assignment.RunE(ctx, data, e.key, value); err != nil {
+			e.reportError(ctx, err)
+		}
+		return
+	}
+
+	e.assignment.Run(ctx, data, e.key, value)
+}
+
+// interpolate resolve templates and variable references in value, then prepare it
+func (e *StdExecutor) interpolate(ctx *Context) (interface{}, error) {
+	raw, err := e.template.resolve(ctx, ctx.StrictTemplate())
+	if err != nil {
+		return nil, err
+	}
+
+	return e.assignment.PrepareValue(raw)
+}
+
+func (e *StdExecutor) reportError(ctx *Context, err error) {
+	reportError(ctx, &EvalError{
+		Expr:     e.expr,
+		Variable: e.key,
+		Executor: true,
+		Err:      err,
+	})
+}
+
+// MarshalJSON output the canonical array form of executor definition: ["$data-key", "$assign", "$assign-value"]
+func (e *StdExecutor) MarshalJSON() ([]byte, error) {
+	return MarshalDefinition([]interface{}{e.key, e.assignmentName, e.rawValue})
 }
 
 //ExecutorGroup
@@ -34,12 +97,38 @@ type ExecutorGroup struct {
 	executors []Executor
 }
 
+// Execute run all executors, or none of them if ctx is interrupted already
 func (e *ExecutorGroup) Execute(ctx *Context, data interface{}) {
+	if ctx.Interrupted() {
+		if trace := ctx.Trace(); trace != nil {
+			trace.Log("INTERRUPTED", ctx.Err())
+		}
+		return
+	}
+
+	// executors are not interrupted halfway
+	ctx = ctx.detach()
+
 	for _, executor := range e.executors {
 		executor.Execute(ctx, data)
 	}
 }
 
+// MarshalJSON output: [[$executor], [$executor]...]
+func (e *ExecutorGroup) MarshalJSON() ([]byte, error) {
+	executors := make([]interface{}, len(e.executors))
+	for i, executor := range e.executors {
+		executors[i] = Definition(executor)
+	}
+
+	return MarshalDefinition(executors)
+}
+
+// Executors return sub executors of group
+func (e *ExecutorGroup) Executors() []Executor {
+	return e.executors
+}
+
 func (e *ExecutorGroup) add(executor Executor) {
 	e.executors = append(e.executors, executor)
 }
@@ -51,49 +140,84 @@ func NewExecutorGroup() *ExecutorGroup {
 }
 
 func NewExecutor(item []interface{}) (Executor, error) {
+	return _defaultEngine.NewExecutor(item)
+}
+
+// compileExecutor build executor, record all problems of definition in errs.
+// path is location of item in the whole definition.
+func (e *Engine) compileExecutor(item []interface{}, path string, errs *ValidationErrors) Executor {
 	if len(item) == 0 {
-		return nil, errors.New("Executor is empty")
+		errs.Add(path, item, ERR_EMPTY, "Executor is empty")
+		return nil
 	}
 
 	if IsArray(item[0]) {
 		group := NewExecutorGroup()
 
-		for _, subitem := range item {
+		for i, subitem := range item {
 			if !IsArray(subitem) {
-				return nil, errors.Errorf("Executor child item is not array. -> %s", jstr(item))
+				errs.Add(indexPath(path, i), subitem, ERR_NOT_ARRAY, "Executor child item is not array. -> %s", jstr(item))
+				continue
 			}
-			if subExecutor, err := NewExecutor(ToArray(subitem)); err != nil {
-				return nil, err
-			} else {
+			if subExecutor := e.compileExecutor(ToArray(subitem), indexPath(path, i), errs); subExecutor != nil {
 				group.add(subExecutor)
 			}
 		}
 
-		return group, nil
+		return group
 	}
 
 	if len(item) != 3 {
-		return nil, errors.Errorf("Executor item must contains 3 elements. -> %s", jstr(item))
+		errs.Add(path, item, ERR_ELEMENT_COUNT, "Executor item must contains 3 elements. -> %s", jstr(item))
+		return nil
 	}
 
-	key, ok := item[0].(string)
-	if !ok {
-		return nil, errors.Errorf("Executor item 1st element (%v) is not string. -> %s", item[0], jstr(item))
+	key, keyOk := item[0].(string)
+	if !keyOk {
+		errs.Add(indexPath(path, 0), item[0], ERR_NOT_STRING, "Executor item 1st element (%v) is not string. -> %s", item[0], jstr(item))
 	}
 
 	assignmentName, ok := item[1].(string)
 	if !ok {
-		return nil, errors.Errorf("Executor item 2nd element (%v) is not string. -> %s", item[1], jstr(item))
+		errs.Add(indexPath(path, 1), item[1], ERR_NOT_STRING, "Executor item 2nd element (%v) is not string. -> %s", item[1], jstr(item))
+		return nil
 	}
 
-	assignment := _assignmentFactory.Get(assignmentName)
+	assignment := e.assignmentFactory.Get(assignmentName)
 	if assignment == nil {
-		return nil, errors.Errorf("Executor with invalid assignment[%s]", assignmentName)
+		errs.Add(indexPath(path, 1), assignmentName, ERR_UNKNOWN_ASSIGNMENT, "Executor with invalid assignment[%s]", assignmentName)
+		return nil
+	}
+	if a, ok := assignment.(engineAssignment); ok {
+		assignment = a.withEngine(e)
 	}
 
-	value, err := assignment.PrepareValue(item[2])
-	if err != nil {
-		return nil, errors.Errorf("Executor assignment[%s] prepare value err:%s", assignmentName, err)
+	// value of nested executors is interpolated by themselves
+	var template *operand
+	value := item[2]
+	if _, ok := assignment.(nestedAssignment); !ok {
+		n := len(*errs)
+		if template, value = e.compileOperand(item[2], true, indexPath(path, 2), errs); len(*errs) > n {
+			return nil
+		}
+	}
+
+	// value with templates is prepared when executor runs
+	if template == nil {
+		var err error
+		value, err = assignment.PrepareValue(value)
+		if verrs, ok := err.(ValidationErrors); ok {
+			// nested definition, e.g. =>
+			*errs = append(*errs, verrs.Prefix(indexPath(path, 2))...)
+			return nil
+		} else if err != nil {
+			errs.Add(indexPath(path, 2), item[2], ERR_INVALID_VALUE, "Executor assignment[%s] prepare value err:%s", assignmentName, err)
+			return nil
+		}
+	}
+
+	if !keyOk {
+		return nil
 	}
 
 	expr := fmt.Sprintf("%s %s %s", key, assignmentName, jstr(value))
@@ -103,7 +227,11 @@ func NewExecutor(item []interface{}) (Executor, error) {
 		key:        key,
 		assignment: assignment,
 		value:      value,
+		template:   template,
+
+		assignmentName: assignmentName,
+		rawValue:       item[2],
 	}
 
-	return executor, nil
+	return executor
 }
@@ -1,9 +1,12 @@
 package core
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func _e(k, op, val interface{}) []interface{} {
@@ -74,3 +77,92 @@ func TestExecutor(t *testing.T) {
 		assert.Equal(t, c.expected, d, "case %d: %v", i, c)
 	}
 }
+
+func TestExecutorMarshalJSON(t *testing.T) {
+	tests := []struct {
+		input    []interface{}
+		expected string
+	}{
+		{
+			_e("foo.bar", "=", 1),
+			`["foo.bar","=",1]`,
+		},
+		{
+			[]interface{}{
+				_e("foo.bar.0", "=", 2),
+				_e("foo", "*=", []interface{}{[]interface{}{1, "a"}, []interface{}{2, "b"}}),
+			},
+			`[["foo.bar.0","=",2],["foo","*=",[[1,"a"],[2,"b"]]]]`,
+		},
+	}
+
+	for i, c := range tests {
+		e, err := NewExecutor(c.input)
+		require.NoError(t, err)
+		b, err := json.Marshal(e)
+		require.NoError(t, err)
+		assert.JSONEq(t, c.expected, string(b), "case %d", i)
+	}
+}
+
+func TestExecutorTemplate(t *testing.T) {
+	ctx := NewContext()
+	ctx.Set("user", map[string]interface{}{"name": "Tom", "tags": []interface{}{"a", "b"}})
+	ctx.Set("city", "Beijing")
+	ctx.Set("link", map[string]interface{}{"url": "https://example.com"})
+
+	executor, err := NewExecutor([]interface{}{
+		_e("banner.title", "=", "Hello ${ctx.user.name}, ${ ctx.city } deals"),
+		_e("banner.link", "=", map[string]interface{}{"$var": "ctx.link"}),
+		_e("banner.tags", "=", "${ctx.user.tags}"),
+		_e("banner.missing", "=", "[${ctx.none}]"),
+		_e("banner.price", "=", "$${price} is $$5"),
+		_e("banner", "+", map[string]interface{}{
+			"items": []interface{}{"${ctx.city}", map[string]interface{}{"$var": "ctx.user.name"}},
+		}),
+		_e("set", "=>", []interface{}{_e("banner.group", "=", "${ctx.city}")}),
+	})
+	require.NoError(t, err)
+
+	data := make(map[string]interface{})
+	executor.Execute(ctx, data)
+	assert.Equal(t, map[string]interface{}{
+		"title":   "Hello Tom, Beijing deals",
+		"link":    map[string]interface{}{"url": "https://example.com"},
+		"tags":    `["a","b"]`,
+		"missing": "[]",
+		"price":   "${price} is $$5",
+		"items":   []interface{}{"Beijing", "Tom"},
+		"group":   "Beijing",
+	}, data["banner"])
+
+	// values are resolved every time executor runs
+	ctx.Set("city", "Shanghai")
+	executor.Execute(ctx, data)
+	assert.Equal(t, "Hello Tom, Shanghai deals", data["banner"].(map[string]interface{})["title"])
+
+	b, err := json.Marshal(executor.(*ExecutorGroup).Executors()[0])
+	require.NoError(t, err)
+	assert.Equal(t, `["banner.title","=","Hello ${ctx.user.name}, ${ ctx.city } deals"]`, string(b))
+
+	// strict mode fails executor on missing variable
+	collector := NewErrorCollector(ERROR_AS_FALSE)
+	sctx := WithContext(context.Background(), WithStrictTemplate(), WithErrorCollector(collector))
+	executor, err = NewExecutor(_e("a", "=", "${ctx.none}"))
+	require.NoError(t, err)
+	data = make(map[string]interface{})
+	executor.Execute(sctx, data)
+	assert.Empty(t, data)
+	require.Equal(t, 1, collector.Len())
+	assert.True(t, collector.Errors()[0].Executor)
+
+	for _, item := range [][]interface{}{
+		_e("a", "=", "${ctx.a"),
+		_e("a", "=", "${}"),
+		_e("a", "=", "${unknown}"),
+		_e("a", "=", map[string]interface{}{"$var": 1}),
+	} {
+		_, err = NewExecutor(item)
+		assert.Error(t, err, "%v", item)
+	}
+}
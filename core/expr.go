@@ -0,0 +1,314 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// ParseCondition build condition from human-readable expression.
+//  e.g.
+//    ctx.user.level >= 3 && (city in ["bj","sh"] || !(ua ~ "/bot/"))
+//
+//  syntax:
+//    expr    : and ( "||" and )*
+//    and     : unary ( "&&" unary )*
+//    unary   : "!" unary | "(" expr ")" | $var-name $op $op-value
+//    op      : registered operation name, e.g. =, !=, >=, ~, in, not in, between
+//    op-value: json value, e.g. 3, "bj", ["bj","sh"], [1,10]
+//
+//  Definitions of condition library are referenced like other conditions: use? = "is_vip", @is_vip = true
+//
+//  The expression is translated to definition data and compiled by NewCondition:
+//    a && b   => ["all?", "=>", [a, b]]
+//    a || b   => ["any?", "=>", [a, b]]
+//    !a       => ["none?", "=>", [a]]
+//    !(a || b)=> ["none?", "=>", [a, b]]
+//    !(a && b)=> ["not?", "=>", [a, b]]
+//
+func ParseCondition(expr string) (Condition, error) {
+	return _defaultEngine.ParseCondition(expr)
+}
+
+// ParseConditionData translate expression to condition definition data. See ParseCondition.
+func ParseConditionData(expr string) ([]interface{}, error) {
+	p := &exprParser{src: expr}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+
+	return node.data(), nil
+}
+
+// FormatCondition render compiled condition into expression that ParseCondition accepts.
+func FormatCondition(c Condition) string {
+	switch v := c.(type) {
+	case *StdCondition:
+		return v.key + " " + v.operationName + " " + jstr(v.rawValue)
+	case *conditionRef:
+		return v.String()
+	case *ConditionGroup:
+		switch v.logic {
+		case LOGIC_ANY:
+			return formatConditions(v.conditions, "||")
+		case LOGIC_NONE:
+			return formatNot(v.conditions, "||")
+		case LOGIC_ANY_NOT:
+			return formatNot(v.conditions, "&&")
+		default:
+			return formatConditions(v.conditions, "&&")
+		}
+	}
+
+	return c.String()
+}
+
+func formatNot(conditions []Condition, op string) string {
+	if len(conditions) == 1 && exprOperator(conditions[0]) == "!" {
+		return "!" + FormatCondition(conditions[0])
+	}
+
+	return "!(" + formatConditions(conditions, op) + ")"
+}
+
+func formatConditions(conditions []Condition, op string) string {
+	items := make([]string, len(conditions))
+	for i, c := range conditions {
+		items[i] = FormatCondition(c)
+		// || has lower precedence than &&
+		if op == "&&" && exprOperator(c) == "||" {
+			items[i] = "(" + items[i] + ")"
+		}
+	}
+
+	return strings.Join(items, " "+op+" ")
+}
+
+// exprOperator return the top operator of formatted condition: "", "!", "&&", "||"
+func exprOperator(c Condition) string {
+	group, ok := c.(*ConditionGroup)
+	if !ok {
+		return ""
+	}
+
+	switch group.logic {
+	case LOGIC_NONE, LOGIC_ANY_NOT:
+		return "!"
+	}
+
+	if len(group.conditions) == 1 {
+		return exprOperator(group.conditions[0])
+	}
+
+	if group.logic == LOGIC_ANY {
+		return "||"
+	}
+
+	return "&&"
+}
+
+// exprNode is node of parsed expression
+type exprNode struct {
+	logic    GROUP_LOGIC
+	item     []interface{} // leaf node: [$var-name, $op, $op-value]
+	children []*exprNode
+}
+
+func (n *exprNode) data() []interface{} {
+	if n.item != nil {
+		return n.item
+	}
+
+	list := make([]interface{}, len(n.children))
+	for i, child := range n.children {
+		list[i] = child.data()
+	}
+
+	for k, v := range groupConditionKeys {
+		if v == n.logic {
+			return []interface{}{k, "=>", list}
+		}
+	}
+
+	return list
+}
+
+type exprParser struct {
+	src string
+	pos int
+}
+
+func (p *exprParser) errorf(format string, a ...interface{}) error {
+	return errors.Errorf("Expression syntax error at %d: %s", p.pos, fmt.Sprintf(format, a...))
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *exprParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+
+	return false
+}
+
+func (p *exprParser) parseOr() (*exprNode, error) {
+	return p.parseBinary("||", LOGIC_ANY, p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (*exprNode, error) {
+	return p.parseBinary("&&", LOGIC_ALL, p.parseUnary)
+}
+
+func (p *exprParser) parseBinary(token string, logic GROUP_LOGIC, next func() (*exprNode, error)) (*exprNode, error) {
+	node, err := next()
+	if err != nil {
+		return nil, err
+	}
+
+	var group *exprNode
+	for p.consume(token) {
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if group == nil {
+			group = &exprNode{logic: logic}
+			group.add(node)
+		}
+		group.add(right)
+	}
+
+	if group != nil {
+		return group, nil
+	}
+
+	return node, nil
+}
+
+// add child, flatten child group with same logic
+func (n *exprNode) add(child *exprNode) {
+	if child.item == nil && child.logic == n.logic {
+		n.children = append(n.children, child.children...)
+	} else {
+		n.children = append(n.children, child)
+	}
+}
+
+func (p *exprParser) parseUnary() (*exprNode, error) {
+	p.skipSpace()
+
+	if p.consume("!") {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if node.item == nil {
+			switch node.logic {
+			case LOGIC_ANY:
+				return &exprNode{logic: LOGIC_NONE, children: node.children}, nil
+			case LOGIC_ALL:
+				return &exprNode{logic: LOGIC_ANY_NOT, children: node.children}, nil
+			case LOGIC_NONE:
+				return &exprNode{logic: LOGIC_ANY, children: node.children}, nil
+			case LOGIC_ANY_NOT:
+				return &exprNode{logic: LOGIC_ALL, children: node.children}, nil
+			}
+		}
+		return &exprNode{logic: LOGIC_NONE, children: []*exprNode{node}}, nil
+	}
+
+	if p.consume("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("missing ')'")
+		}
+		return node, nil
+	}
+
+	return p.parseComparison()
+}
+
+// operation characters, name of symbol operation is composed by them
+const exprOpChars = "=!<>~*^$%@#+/"
+
+func (p *exprParser) parseComparison() (*exprNode, error) {
+	p.skipSpace()
+
+	// variable name
+	start := p.pos
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		if unicode.IsSpace(rune(ch)) || strings.IndexByte("=!<>~()&|\"", ch) >= 0 {
+			break
+		}
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	if name == "" {
+		return nil, p.errorf("missing variable name")
+	}
+
+	// operation
+	p.skipSpace()
+	start = p.pos
+	if p.pos < len(p.src) && strings.IndexByte(exprOpChars, p.src[p.pos]) >= 0 {
+		for p.pos < len(p.src) && strings.IndexByte(exprOpChars, p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+	} else {
+		p.scanWord()
+	}
+	op := p.src[start:p.pos]
+	if op == "" {
+		return nil, p.errorf("missing operation after %s", name)
+	}
+	if op == "==" {
+		op = "="
+	} else if op == "not" {
+		p.skipSpace()
+		wordStart := p.pos
+		p.scanWord()
+		op = op + " " + p.src[wordStart:p.pos]
+	}
+
+	// operation value
+	p.skipSpace()
+	decoder := json.NewDecoder(strings.NewReader(p.src[p.pos:]))
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, p.errorf("invalid value of %s %s: %s", name, op, err)
+	}
+	p.pos += int(decoder.InputOffset())
+
+	return &exprNode{item: []interface{}{name, op, value}}, nil
+}
+
+func (p *exprParser) scanWord() {
+	for p.pos < len(p.src) {
+		ch := rune(p.src[p.pos])
+		if !(unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '_') {
+			break
+		}
+		p.pos++
+	}
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCondition(t *testing.T) {
+	ctx := NewContext()
+	ctx.Set("user", map[string]interface{}{"level": 3, "city": "bj"})
+	ctx.Set("ua", "Googlebot/2.1")
+
+	tests := []struct {
+		expr     string
+		data     []interface{}
+		format   string
+		expected bool
+		hasError bool
+	}{
+		{
+			expr:     `ctx.user.level >= 3`,
+			data:     []interface{}{"ctx.user.level", ">=", float64(3)},
+			format:   `ctx.user.level >= 3`,
+			expected: true,
+		},
+		{
+			expr: `ctx.user.level>=3 && (ctx.user.city in ["bj","sh"] || !(ctx.ua ~ "/bot/"))`,
+			data: []interface{}{"all?", "=>", []interface{}{
+				[]interface{}{"ctx.user.level", ">=", float64(3)},
+				[]interface{}{"any?", "=>", []interface{}{
+					[]interface{}{"ctx.user.city", "in", []interface{}{"bj", "sh"}},
+					[]interface{}{"none?", "=>", []interface{}{
+						[]interface{}{"ctx.ua", "~", "/bot/"},
+					}},
+				}},
+			}},
+			format:   `ctx.user.level >= 3 && (ctx.user.city in ["bj","sh"] || !(ctx.ua ~ "/bot/"))`,
+			expected: true,
+		},
+		{
+			expr: `!(ctx.user.level == 3 && ctx.user.city not in "sh,gz") || ctx.ua ~ "bot" && succ = true`,
+			data: []interface{}{"any?", "=>", []interface{}{
+				[]interface{}{"not?", "=>", []interface{}{
+					[]interface{}{"ctx.user.level", "=", float64(3)},
+					[]interface{}{"ctx.user.city", "not in", "sh,gz"},
+				}},
+				[]interface{}{"all?", "=>", []interface{}{
+					[]interface{}{"ctx.ua", "~", "bot"},
+					[]interface{}{"succ", "=", true},
+				}},
+			}},
+			format:   `!(ctx.user.level = 3 && ctx.user.city not in "sh,gz") || ctx.ua ~ "bot" && succ = true`,
+			expected: true,
+		},
+		{
+			expr:     `ctx.user.level between [1, 2]`,
+			format:   `ctx.user.level between [1,2]`,
+			expected: false,
+		},
+		{expr: `ctx.user.level >= `, hasError: true},
+		{expr: `(ctx.user.level >= 3`, hasError: true},
+		{expr: `ctx.user.level >= 3 ctx.a = 1`, hasError: true},
+		{expr: `ctx.user.level unknown-op 3`, hasError: true},
+	}
+
+	for i, c := range tests {
+		cond, err := ParseCondition(c.expr)
+		if c.hasError {
+			t.Log(err)
+			assert.Error(t, err, "case %d", i)
+			continue
+		}
+		require.NoError(t, err, "case %d", i)
+		if c.data != nil {
+			data, _ := ParseConditionData(c.expr)
+			assert.Equal(t, c.data, data, "case %d", i)
+		}
+		assert.Equal(t, c.expected, cond.Success(ctx), "case %d", i)
+		assert.Equal(t, c.format, FormatCondition(cond), "case %d", i)
+
+		cond2, err := ParseCondition(FormatCondition(cond))
+		require.NoError(t, err, "case %d", i)
+		assert.Equal(t, cond.String(), cond2.String(), "case %d", i)
+	}
+}
+
+func TestFormatCondition(t *testing.T) {
+	cond, err := NewCondition([]interface{}{
+		[]interface{}{"ctx.a", "=", 1},
+		[]interface{}{"any?", "=>", []interface{}{
+			[]interface{}{"ctx.b", "in", []interface{}{1, 2}},
+			[]interface{}{"ctx.c", "!=", "x"},
+		}},
+		[]interface{}{"none?", "=>", []interface{}{
+			[]interface{}{"ctx.d", "=", true},
+		}},
+	}, LOGIC_ALL)
+	require.NoError(t, err)
+
+	assert.Equal(t, `ctx.a = 1 && (ctx.b in [1,2] || ctx.c != "x") && !(ctx.d = true)`, FormatCondition(cond))
+
+	// library references
+	e := NewEngine(InheritDefault())
+	require.NoError(t, e.ConditionLibrary().Define(map[string]interface{}{
+		"is_vip":   []interface{}{"ctx.level", ">=", 3},
+		"is_night": []interface{}{"ctx.hour", ">=", 18},
+	}))
+	cond, err = e.NewCondition([]interface{}{
+		[]interface{}{"use?", "=", "is_vip"},
+		[]interface{}{"any?", "=>", []interface{}{
+			[]interface{}{"@is_night", "=", true},
+			[]interface{}{"ctx.a", "=", 1},
+		}},
+	}, LOGIC_ALL)
+	require.NoError(t, err)
+	expr := FormatCondition(cond)
+	assert.Equal(t, `use? = "is_vip" && (@is_night = true || ctx.a = 1)`, expr)
+
+	parsed, err := e.ParseCondition(expr)
+	require.NoError(t, err)
+	assert.Equal(t, expr, FormatCondition(parsed))
+	assert.Equal(t, []string{"is_vip", "is_night"}, ConditionReferences(parsed))
+}
@@ -0,0 +1,326 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ConditionLibrary is registry of named conditions, each definition is compiled once and shared by filters.
+// Conditions reference definitions in two forms:
+//  ["use?", "=", "is_vip"]   // the condition is_vip itself
+//  ["@is_vip", "=", true]    // variable @is_vip is result of condition is_vip
+// Definitions can reference each other, cycles are reported by Define.
+type ConditionLibrary struct {
+	mu         sync.RWMutex
+	engine     *Engine
+	conditions map[string]*namedCondition
+
+	// search definition in parent if not found
+	parent *ConditionLibrary
+}
+
+// namedCondition is compiled definition of library
+type namedCondition struct {
+	name      string
+	condition Condition
+	// definitions referenced by condition, directly or not
+	references []string
+}
+
+func newConditionLibrary(engine *Engine) *ConditionLibrary {
+	return &ConditionLibrary{
+		engine:     engine,
+		conditions: make(map[string]*namedCondition),
+	}
+}
+
+// GetConditionLibrary return condition library of the default engine
+func GetConditionLibrary() *ConditionLibrary {
+	return _defaultEngine.library
+}
+
+// Define compile and add definitions, e.g. decoded from json:
+//  {
+//    "is_vip": ["data.user.level", ">=", 3],
+//    "is_night": [["time", "between", "18:00,23:59"], ["ctx.country", "=", "CN"]],
+//    "is_vip_at_night": [["use?", "=", "is_vip"], ["use?", "=", "is_night"]]
+//  }
+// Definitions are added only if all of them are valid, otherwise ValidationErrors is returned.
+// Defined names can not be redefined.
+func (l *ConditionLibrary) Define(definitions map[string]interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := &libraryBuild{
+		library:     l,
+		definitions: definitions,
+		compiled:    make(map[string]*namedCondition),
+		failed:      make(map[string]bool),
+		errs:        make(ValidationErrors, 0),
+	}
+	// compile with engine that resolves references in the building definitions
+	engine := *l.engine
+	engine.resolver = b
+	b.engine = &engine
+
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "" || strings.HasPrefix(name, CONDITION_VARIABLE_PREFIX) {
+			b.errs.Add(namePath(name), name, ERR_INVALID_VALUE, "Invalid condition name[%s]", name)
+			continue
+		}
+		if l.lookup(name) != nil {
+			b.errs.Add(namePath(name), name, ERR_INVALID_VALUE, "Condition[%s] is defined already", name)
+			continue
+		}
+		b.resolve(name, namePath(name), &b.errs)
+	}
+
+	if len(b.errs) > 0 {
+		return b.errs
+	}
+
+	for name, c := range b.compiled {
+		l.conditions[name] = c
+	}
+
+	return nil
+}
+
+// List return names of definitions, including the ones in parent
+func (l *ConditionLibrary) List() []string {
+	var names []string
+	if l.parent != nil {
+		names = l.parent.List()
+	}
+
+	l.mu.RLock()
+	for name := range l.conditions {
+		names = append(names, name)
+	}
+	l.mu.RUnlock()
+
+	sort.Strings(names)
+	j := 0
+	for i, name := range names {
+		if i == 0 || name != names[j-1] {
+			names[j] = name
+			j++
+		}
+	}
+
+	return names[:j]
+}
+
+// Get return compiled condition of definition
+func (l *ConditionLibrary) Get(name string) (Condition, bool) {
+	if c := l.get(name); c != nil {
+		return c.condition, true
+	}
+
+	return nil, false
+}
+
+func (l *ConditionLibrary) get(name string) *namedCondition {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.lookup(name)
+}
+
+// lookup search definition without lock of l
+func (l *ConditionLibrary) lookup(name string) *namedCondition {
+	if c, ok := l.conditions[name]; ok {
+		return c
+	}
+
+	if l.parent != nil {
+		return l.parent.get(name)
+	}
+
+	return nil
+}
+
+// resolve implements conditionResolver
+func (l *ConditionLibrary) resolve(name string, path string, errs *ValidationErrors) *namedCondition {
+	c := l.get(name)
+	if c == nil {
+		errs.Add(path, name, ERR_UNKNOWN_CONDITION, "Unknown condition[%s]", name)
+	}
+
+	return c
+}
+
+// conditionResolver resolves references of named conditions while compiling
+type conditionResolver interface {
+	resolve(name string, path string, errs *ValidationErrors) *namedCondition
+}
+
+// libraryBuild compiles definitions of a Define call, detects reference cycles
+type libraryBuild struct {
+	library     *ConditionLibrary
+	engine      *Engine
+	definitions map[string]interface{}
+	compiled    map[string]*namedCondition
+	failed      map[string]bool
+	// definitions being compiled
+	visiting []string
+	errs     ValidationErrors
+}
+
+func (b *libraryBuild) resolve(name string, path string, errs *ValidationErrors) *namedCondition {
+	if c, ok := b.compiled[name]; ok {
+		return c
+	}
+	// errors are reported already
+	if b.failed[name] {
+		return nil
+	}
+
+	definition, ok := b.definitions[name]
+	if !ok {
+		if c := b.library.lookup(name); c != nil {
+			return c
+		}
+		errs.Add(path, name, ERR_UNKNOWN_CONDITION, "Unknown condition[%s]", name)
+		return nil
+	}
+
+	for i, visiting := range b.visiting {
+		if visiting == name {
+			cycle := append(append([]string(nil), b.visiting[i:]...), name)
+			errs.Add(path, name, ERR_CONDITION_CYCLE, "Condition reference cycle: %s", strings.Join(cycle, " -> "))
+			return nil
+		}
+	}
+
+	if !IsArray(definition) {
+		b.failed[name] = true
+		b.errs.Add(namePath(name), definition, ERR_NOT_ARRAY, "Condition[%s] definition must be an array", name)
+		return nil
+	}
+
+	b.visiting = append(b.visiting, name)
+	n := len(b.errs)
+	condition := b.engine.compileCondition(ToArray(definition), LOGIC_ALL, namePath(name), &b.errs)
+	b.visiting = b.visiting[:len(b.visiting)-1]
+
+	if condition == nil || len(b.errs) > n {
+		b.failed[name] = true
+		return nil
+	}
+
+	c := &namedCondition{
+		name:       name,
+		condition:  condition,
+		references: ConditionReferences(condition),
+	}
+	b.compiled[name] = c
+
+	return c
+}
+
+func namePath(name string) string {
+	return "[" + name + "]"
+}
+
+// ConditionReferences return names of definitions referenced by condition in order, directly or not
+func ConditionReferences(c Condition) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+
+	add := func(c *namedCondition) {
+		for _, name := range append([]string{c.name}, c.references...) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	var walk func(Condition)
+	walk = func(c Condition) {
+		switch c := c.(type) {
+		case *conditionRef:
+			add(c.named)
+		case *StdCondition:
+			for _, v := range append([]Variable{c.Variable()}, c.OperandVariables()...) {
+				if v, ok := v.(*conditionVariable); ok {
+					add(v.named)
+				}
+			}
+		case *ConditionGroup:
+			for _, sub := range c.Conditions() {
+				walk(sub)
+			}
+		}
+	}
+	walk(c)
+
+	return names
+}
+
+// conditionRef is condition that references definition: ["use?", "=", "$name"]
+type conditionRef struct {
+	named *namedCondition
+}
+
+func (c *conditionRef) Success(ctx *Context) (ok bool) {
+	// definition is recorded as child of the reference
+	if recorder := ctx.Recorder(); recorder != nil {
+		r := &ConditionResult{
+			Expr:      c.String(),
+			Variable:  CONDITION_REF_KEY,
+			Operation: "=",
+			Operand:   c.named.name,
+		}
+		recorder.enterGroup(r)
+		defer func() {
+			r.Succ = ok
+			recorder.leaveGroup()
+		}()
+	}
+
+	return c.named.condition.Success(ctx)
+}
+
+// String output expression that ParseCondition accepts: use? = "$name"
+func (c *conditionRef) String() string {
+	return fmt.Sprintf("%s = %s", CONDITION_REF_KEY, jstr(c.named.name))
+}
+
+func (c *conditionRef) MarshalJSON() ([]byte, error) {
+	return MarshalDefinition([]interface{}{CONDITION_REF_KEY, "=", c.named.name})
+}
+
+// conditionVariable is variable "@$name", its value is result of definition
+type conditionVariable struct {
+	named *namedCondition
+}
+
+func (v *conditionVariable) Name() string {
+	return CONDITION_VARIABLE_PREFIX + v.named.name
+}
+
+// result depends on data that may be changed by executors
+func (v *conditionVariable) Cacheable() bool {
+	return false
+}
+
+func (v *conditionVariable) Value(ctx *Context) interface{} {
+	return v.named.condition.Success(ctx)
+}
+
+const (
+	// CONDITION_REF_KEY is key of condition that references definition in library
+	CONDITION_REF_KEY = "use?"
+	// CONDITION_VARIABLE_PREFIX is prefix of variable whose value is result of definition in library
+	CONDITION_VARIABLE_PREFIX = "@"
+)
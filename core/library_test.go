@@ -0,0 +1,119 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionLibrary(t *testing.T) {
+	e := NewEngine(InheritDefault())
+	lib := e.ConditionLibrary()
+
+	require.NoError(t, lib.Define(map[string]interface{}{
+		"is_vip":     []interface{}{"data.level", ">=", 3},
+		"is_beijing": []interface{}{"ctx.city", "=", "beijing"},
+		"is_vip_in_beijing": []interface{}{
+			[]interface{}{"use?", "=", "is_vip"},
+			[]interface{}{"@is_beijing", "=", true},
+		},
+	}))
+	assert.Equal(t, []string{"is_beijing", "is_vip", "is_vip_in_beijing"}, lib.List())
+
+	c, err := e.NewCondition([]interface{}{"use?", "=", "is_vip_in_beijing"}, LOGIC_ALL)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"is_vip_in_beijing", "is_vip", "is_beijing"}, ConditionReferences(c))
+
+	shared, ok := lib.Get("is_vip")
+	require.True(t, ok)
+	c2, err := e.NewCondition([]interface{}{"use?", "=", "is_vip"}, LOGIC_ALL)
+	require.NoError(t, err)
+	assert.Same(t, shared, c2.(*conditionRef).named.condition, "compiled once")
+
+	b, err := json.Marshal(c)
+	require.NoError(t, err)
+	assert.Equal(t, `["use?","=","is_vip_in_beijing"]`, string(b))
+
+	names := make([]string, 0)
+	for _, v := range ConditionVariables(c) {
+		names = append(names, v.Name())
+	}
+	assert.Equal(t, []string{"data.level", "@is_beijing", "ctx.city"}, names)
+
+	ctx := WithData(NewContext(), map[string]interface{}{"level": 3})
+	ctx.Set("city", "beijing")
+	assert.True(t, c.Success(ctx))
+	ctx.Set("city", "shanghai")
+	assert.False(t, c.Success(ctx))
+
+	c, err = e.NewCondition([]interface{}{"@is_vip", "=", false}, LOGIC_ALL)
+	require.NoError(t, err)
+	assert.False(t, c.Success(ctx))
+
+	// not defined in default engine
+	_, err = NewCondition([]interface{}{"use?", "=", "is_vip"}, LOGIC_ALL)
+	assert.Error(t, err)
+
+	for _, item := range [][]interface{}{
+		{"use?", "=", "unknown"},
+		{"@unknown", "=", true},
+		{"use?", "!=", "is_vip"},
+		{"use?", "=", 1},
+	} {
+		_, err = e.NewCondition(item, LOGIC_ALL)
+		assert.Error(t, err, "%v", item)
+	}
+
+	// child engine inherits definitions
+	child := NewEngine(InheritDefault())
+	child.library.parent = lib
+	require.NoError(t, child.ConditionLibrary().Define(map[string]interface{}{
+		"not_vip": []interface{}{"@is_vip", "=", false},
+	}))
+	assert.Equal(t, []string{"is_beijing", "is_vip", "is_vip_in_beijing", "not_vip"}, child.ConditionLibrary().List())
+}
+
+func TestConditionLibraryErrors(t *testing.T) {
+	lib := NewEngine(InheritDefault()).ConditionLibrary()
+	require.NoError(t, lib.Define(map[string]interface{}{
+		"a": []interface{}{"succ", "=", true},
+	}))
+
+	paths := func(err error) []string {
+		require.Error(t, err)
+		errs, ok := err.(ValidationErrors)
+		require.True(t, ok)
+		paths := make([]string, len(errs))
+		for i, e := range errs {
+			paths[i] = e.Path + " " + string(e.Code)
+		}
+		return paths
+	}
+
+	assert.Equal(t, []string{
+		"[a] INVALID_VALUE",
+		"[d][2] CONDITION_CYCLE",
+		"[e] NOT_ARRAY",
+		"[f][2] UNKNOWN_CONDITION",
+		"[g][1] UNKNOWN_OPERATION",
+	}, paths(lib.Define(map[string]interface{}{
+		"a": []interface{}{"succ", "=", true},
+		"b": []interface{}{"use?", "=", "c"},
+		"c": []interface{}{"@d", "=", true},
+		"d": []interface{}{"use?", "=", "b"},
+		"e": "succ",
+		"f": []interface{}{"use?", "=", "unknown"},
+		"g": []interface{}{"succ", "~~", 1},
+		"h": []interface{}{"use?", "=", "a"},
+	})))
+
+	// nothing is defined if any definition is invalid
+	assert.Equal(t, []string{"a"}, lib.List())
+
+	err := lib.Define(map[string]interface{}{
+		"self": []interface{}{"use?", "=", "self"},
+	})
+	assert.Contains(t, err.Error(), "self -> self")
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Observer receives evaluation metrics, set it with context option WithObserver.
+// Methods are called from evaluating goroutines, implementation must be concurrency-safe.
+type Observer interface {
+	// ObserveFilter is called after filter or filter group runs, hit is the run result
+	ObserveFilter(name string, hit bool, duration time.Duration)
+	// ObserveCondition is called after condition is evaluated, expr contains values of condition
+	ObserveCondition(expr string, succ bool, duration time.Duration)
+	// ObserveVariable is called after variable value is got, cached is true if value is from cache
+	ObserveVariable(name string, cached bool, duration time.Duration)
+}
+
+const (
+	METRIC_FILTER    = "filter"
+	METRIC_CONDITION = "condition"
+	METRIC_VARIABLE  = "variable"
+)
+
+// MetricStat is aggregated metric of filter, condition or variable.
+//   Hits : filter matched / condition succeeded / variable value from cache
+type MetricStat struct {
+	Count    int64         `json:"count"`
+	Hits     int64         `json:"hits"`
+	Duration time.Duration `json:"duration"` // total duration
+}
+
+// Metrics is in-memory aggregator implements Observer.
+// Conditions are keyed by expression, so they are aggregated only if WithConditionMetrics is set,
+// to avoid unbounded names from conditions with many distinct values.
+//  Expose with expvar:
+//    expvar.Publish("filter", metrics)
+//  Expose with Prometheus text format:
+//    http.Handle("/metrics", metrics)
+//
+type Metrics struct {
+	mu         sync.Mutex
+	stats      map[string]map[string]*MetricStat
+	conditions bool
+}
+
+// MetricsOption is option of NewMetrics
+type MetricsOption func(*Metrics)
+
+// WithConditionMetrics enable metrics of conditions keyed by expression
+func WithConditionMetrics() MetricsOption {
+	return func(m *Metrics) {
+		m.conditions = true
+	}
+}
+
+func NewMetrics(opts ...MetricsOption) *Metrics {
+	m := &Metrics{
+		stats: map[string]map[string]*MetricStat{
+			METRIC_FILTER:    make(map[string]*MetricStat),
+			METRIC_CONDITION: make(map[string]*MetricStat),
+			METRIC_VARIABLE:  make(map[string]*MetricStat),
+		},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+func (m *Metrics) observe(kind, name string, hit bool, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stat, ok := m.stats[kind][name]
+	if !ok {
+		stat = &MetricStat{}
+		m.stats[kind][name] = stat
+	}
+
+	stat.Count++
+	if hit {
+		stat.Hits++
+	}
+	stat.Duration += duration
+}
+
+func (m *Metrics) ObserveFilter(name string, hit bool, duration time.Duration) {
+	m.observe(METRIC_FILTER, name, hit, duration)
+}
+
+func (m *Metrics) ObserveCondition(expr string, succ bool, duration time.Duration) {
+	if !m.conditions {
+		return
+	}
+	m.observe(METRIC_CONDITION, expr, succ, duration)
+}
+
+func (m *Metrics) ObserveVariable(name string, cached bool, duration time.Duration) {
+	m.observe(METRIC_VARIABLE, name, cached, duration)
+}
+
+// Snapshot return copy of metrics: kind => name => stat
+func (m *Metrics) Snapshot() map[string]map[string]MetricStat {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]map[string]MetricStat, len(m.stats))
+	for kind, stats := range m.stats {
+		snapshot[kind] = make(map[string]MetricStat, len(stats))
+		for name, stat := range stats {
+			snapshot[kind][name] = *stat
+		}
+	}
+
+	return snapshot
+}
+
+// Reset clear all metrics
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for kind := range m.stats {
+		m.stats[kind] = make(map[string]*MetricStat)
+	}
+}
+
+// String implements expvar.Var
+func (m *Metrics) String() string {
+	b, _ := json.Marshal(m.Snapshot())
+
+	return string(b)
+}
+
+// ServeHTTP write metrics in Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	snapshot := m.Snapshot()
+	hitsHelp := map[string]string{
+		METRIC_FILTER:    "matched",
+		METRIC_CONDITION: "succeeded",
+		METRIC_VARIABLE:  "value from cache",
+	}
+
+	var b strings.Builder
+	for _, kind := range []string{METRIC_FILTER, METRIC_CONDITION, METRIC_VARIABLE} {
+		stats := snapshot[kind]
+		names := make([]string, 0, len(stats))
+		for name := range stats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		metric := "filter_" + kind
+		fmt.Fprintf(&b, "# HELP %s_total Number of %s evaluations.\n# TYPE %s_total counter\n", metric, kind, metric)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s_total{name=\"%s\"} %d\n", metric, promLabel(name), stats[name].Count)
+		}
+		fmt.Fprintf(&b, "# HELP %s_hits_total Number of %s evaluations with %s.\n# TYPE %s_hits_total counter\n", metric, kind, hitsHelp[kind], metric)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s_hits_total{name=\"%s\"} %d\n", metric, promLabel(name), stats[name].Hits)
+		}
+		fmt.Fprintf(&b, "# HELP %s_duration_seconds_total Total duration of %s evaluations.\n# TYPE %s_duration_seconds_total counter\n", metric, kind, metric)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s_duration_seconds_total{name=\"%s\"} %g\n", metric, promLabel(name), stats[name].Duration.Seconds())
+		}
+	}
+
+	w.Write([]byte(b.String()))
+}
+
+var _promLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promLabel(v string) string {
+	return _promLabelReplacer.Replace(v)
+}
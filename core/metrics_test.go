@@ -0,0 +1,62 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics(WithConditionMetrics())
+	ctx := WithContext(NewContext(), WithObserver(metrics))
+	ctx.Set("a", 1)
+
+	cond, err := NewCondition([]interface{}{
+		[]interface{}{"ctx.a", "=", 1},
+		[]interface{}{"succ", "=", false},
+	}, LOGIC_ALL)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		assert.False(t, cond.Success(ctx))
+	}
+
+	snapshot := metrics.Snapshot()
+	assert.Equal(t, int64(3), snapshot[METRIC_CONDITION]["ctx.a = 1"].Count)
+	assert.Equal(t, int64(3), snapshot[METRIC_CONDITION]["ctx.a = 1"].Hits)
+	assert.Equal(t, int64(3), snapshot[METRIC_CONDITION]["succ = false"].Count)
+	assert.Equal(t, int64(0), snapshot[METRIC_CONDITION]["succ = false"].Hits)
+	assert.Equal(t, int64(3), snapshot[METRIC_VARIABLE]["succ"].Count)
+	assert.Equal(t, int64(2), snapshot[METRIC_VARIABLE]["succ"].Hits, "cached")
+	assert.Equal(t, int64(0), snapshot[METRIC_VARIABLE]["ctx.a"].Hits, "uncacheable")
+
+	// values are the same with recorder
+	ctx = WithContext(ctx, WithRecorder(&Recorder{}))
+	cond.Success(ctx)
+	assert.Equal(t, int64(4), metrics.Snapshot()[METRIC_VARIABLE]["ctx.a"].Count)
+
+	var v map[string]map[string]MetricStat
+	require.NoError(t, json.Unmarshal([]byte(metrics.String()), &v))
+	assert.Equal(t, int64(4), v[METRIC_CONDITION]["ctx.a = 1"].Count)
+
+	metrics.ObserveFilter(`a"b`, true, 0)
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE filter_condition_total counter\n")
+	assert.Contains(t, body, `filter_condition_total{name="ctx.a = 1"} 4`)
+	assert.Contains(t, body, `filter_filter_hits_total{name="a\"b"} 1`)
+	assert.Contains(t, body, `filter_variable_duration_seconds_total{name="succ"} `)
+
+	metrics.Reset()
+	assert.Empty(t, metrics.Snapshot()[METRIC_CONDITION])
+
+	// conditions are not aggregated by default
+	metrics = NewMetrics()
+	cond.Success(WithContext(NewContext(), WithObserver(metrics)))
+	assert.Empty(t, metrics.Snapshot()[METRIC_CONDITION])
+	assert.NotEmpty(t, metrics.Snapshot()[METRIC_VARIABLE])
+}
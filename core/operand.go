@@ -0,0 +1,219 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/techxmind/go-utils/itype"
+)
+
+// VARIABLE_REF_KEY is key of variable reference in operation or assignment value, e.g.
+//  ["data.price", "<", {"$var": "data.original_price"}]
+//  ["data.price", "between", [{"$var": "data.min_price"}, 100]]
+//  ["banner.link", "=", {"$var": "ctx.link"}]
+const VARIABLE_REF_KEY = "$var"
+
+// operand is operation or assignment value that contains variable references or string templates,
+// they are resolved and the value is prepared every time condition or executor runs.
+type operand struct {
+	value     interface{}
+	variables []Variable
+}
+
+// variableRef is resolved by variable at index of operand variables
+type variableRef int
+
+// stringTemplate is string with variables, e.g. "Hello ${ctx.user.name}"
+type stringTemplate []templatePart
+
+// templatePart is text, or variable at index of operand variables if text is empty
+type templatePart struct {
+	text     string
+	variable int
+}
+
+// compileOperand compile variable references in value, string templates are compiled too if interpolate is true.
+// Return nil operand and the value with escapes resolved if value contains no variable.
+func (e *Engine) compileOperand(value interface{}, interpolate bool, path string, errs *ValidationErrors) (*operand, interface{}) {
+	o := &operand{}
+	n := len(*errs)
+	o.value = e.compileOperandValue(o, value, interpolate, path, errs)
+
+	if len(*errs) > n {
+		return nil, value
+	}
+
+	if len(o.variables) == 0 {
+		return nil, o.value
+	}
+
+	return o, value
+}
+
+func (e *Engine) compileOperandValue(o *operand, value interface{}, interpolate bool, path string, errs *ValidationErrors) interface{} {
+	switch v := value.(type) {
+	case string:
+		if !interpolate || !strings.Contains(v, "${") {
+			return v
+		}
+		return e.compileStringTemplate(o, v, path, errs)
+	case map[string]interface{}:
+		if ref, ok := v[VARIABLE_REF_KEY]; ok && len(v) == 1 {
+			name, ok := ref.(string)
+			if !ok {
+				errs.Add(path+"."+VARIABLE_REF_KEY, ref, ERR_NOT_STRING, "Variable reference must be string. -> %s", jstr(v))
+				return nil
+			}
+			variable := e.compileVariable(name, path+"."+VARIABLE_REF_KEY, v, errs)
+			if variable == nil {
+				return nil
+			}
+			o.variables = append(o.variables, variable)
+			return variableRef(len(o.variables) - 1)
+		}
+
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[key] = e.compileOperandValue(o, val, interpolate, path+"."+key, errs)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = e.compileOperandValue(o, val, interpolate, indexPath(path, i), errs)
+		}
+		return l
+	}
+
+	return value
+}
+
+// compileStringTemplate compile "${name}" in s to variables, "$${" is escape of "${".
+// Return string if s contains escapes only.
+func (e *Engine) compileStringTemplate(o *operand, s string, path string, errs *ValidationErrors) interface{} {
+	var (
+		parts []templatePart
+		text  strings.Builder
+	)
+
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "$${") {
+			text.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			text.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			errs.Add(path, s, ERR_INVALID_VALUE, "Unterminated template variable. -> %s", s)
+			return nil
+		}
+		name := strings.TrimSpace(s[i+2 : i+end])
+		if name == "" {
+			errs.Add(path, s, ERR_INVALID_VALUE, "Empty template variable. -> %s", s)
+			return nil
+		}
+		variable := e.compileVariable(name, path, s, errs)
+		if variable == nil {
+			return nil
+		}
+
+		if text.Len() > 0 {
+			parts = append(parts, templatePart{text: text.String()})
+			text.Reset()
+		}
+		o.variables = append(o.variables, variable)
+		parts = append(parts, templatePart{variable: len(o.variables) - 1})
+		i += end + 1
+	}
+
+	if len(parts) == 0 {
+		return text.String()
+	}
+	if text.Len() > 0 {
+		parts = append(parts, templatePart{text: text.String()})
+	}
+
+	return stringTemplate(parts)
+}
+
+// resolve return value with variable references and templates replaced by values of variables.
+// Variable without value is nil, or "" in string template. Return error if strict is true.
+func (o *operand) resolve(ctx *Context, strict bool) (interface{}, error) {
+	values := make([]interface{}, len(o.variables))
+	for i, v := range o.variables {
+		value, err := GetVariableValueE(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil && strict {
+			return nil, errors.Errorf("Variable[%s] has no value", v.Name())
+		}
+		values[i] = value
+	}
+
+	return resolveOperandValue(o.value, values), nil
+}
+
+// placeholder return value to check shape of at compile time, variables are resolved as nil.
+// Return false if shape is unknown until variables are resolved, e.g. value is a variable reference.
+func (o *operand) placeholder(value interface{}) (interface{}, bool) {
+	if o == nil {
+		return value, true
+	}
+
+	if _, ok := o.value.([]interface{}); !ok {
+		return nil, false
+	}
+
+	return resolveOperandValue(o.value, make([]interface{}, len(o.variables))), true
+}
+
+func resolveOperandValue(value interface{}, values []interface{}) interface{} {
+	switch v := value.(type) {
+	case variableRef:
+		return values[v]
+	case stringTemplate:
+		var s strings.Builder
+		for _, part := range v {
+			if part.text != "" {
+				s.WriteString(part.text)
+			} else {
+				s.WriteString(templateString(values[part.variable]))
+			}
+		}
+		return s.String()
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[key] = resolveOperandValue(val, values)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = resolveOperandValue(val, values)
+		}
+		return l
+	}
+
+	return value
+}
+
+// templateString format value of variable in string template, composite value is formatted as json
+func templateString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	if IsScalar(v) {
+		return itype.String(v)
+	}
+
+	return jstr(v)
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"errors"
 	"fmt"
+	"math"
 	"regexp"
 	"strings"
 
@@ -10,26 +11,31 @@ import (
 	"github.com/techxmind/go-utils/itype"
 )
 
-var _operationFactory OperationFactory
+var _operationFactory = newStdOperationFactory()
 
 func init() {
-	_operationFactory = &stdOperationFactory{
-		operations: map[string]Operation{
-			"=":       &EqualOperation{stringer: stringer("=")},
-			"!=":      &NotEqualOperation{stringer: stringer("!=")},
-			">":       &GtOperation{stringer: stringer(">")},
-			">=":      &GeOperation{stringer: stringer(">=")},
-			"<":       &LtOperation{stringer: stringer("<")},
-			"<=":      &LeOperation{stringer: stringer("<=")},
-			"between": &BetweenOperation{stringer: stringer("between")},
-			"in":      &InOperation{stringer: stringer("in")},
-			"not in":  &NotInOperation{stringer: stringer("not in")},
-			"~":       &MatchOperation{stringer: stringer("~")},
-			"!~":      &NotMatchOperation{stringer: stringer("!~")},
-			"any":     &AnyOperation{stringer: stringer("any")},
-			"has":     &HasOperation{stringer: stringer("has")},
-			"none":    &NoneOperation{stringer: stringer("none")},
-		},
+	operations := map[string]Operation{
+		"=":       &EqualOperation{stringer: stringer("=")},
+		"!=":      &NotEqualOperation{stringer: stringer("!=")},
+		">":       &GtOperation{stringer: stringer(">")},
+		">=":      &GeOperation{stringer: stringer(">=")},
+		"<":       &LtOperation{stringer: stringer("<")},
+		"<=":      &LeOperation{stringer: stringer("<=")},
+		"between": &BetweenOperation{stringer: stringer("between")},
+		"in":      &InOperation{stringer: stringer("in")},
+		"not in":  &NotInOperation{stringer: stringer("not in")},
+		"~":       &MatchOperation{stringer: stringer("~")},
+		"!~":      &NotMatchOperation{stringer: stringer("!~")},
+		"any":     &AnyOperation{stringer: stringer("any")},
+		"has":     &HasOperation{stringer: stringer("has")},
+		"none":    &NoneOperation{stringer: stringer("none")},
+		"bucket":  &BucketOperation{stringer: stringer("bucket")},
+	}
+
+	for name, op := range operations {
+		_operationFactory.Register(op, name)
+		// built-in operations can not be overridden in default engine
+		_operationFactory.Protect(name)
 	}
 }
 
@@ -39,9 +45,19 @@ type Operation interface {
 	String() string
 }
 
+// OperationFactory is concurrency-safe registry of operations
 type OperationFactory interface {
 	Get(string) Operation
+	// Register operation with names, overriding protected name is logged
 	Register(Operation, ...string)
+	// TryRegister register operation only if none of the names is registered
+	TryRegister(Operation, ...string) error
+	// Unregister operation names, protected names are kept
+	Unregister(...string)
+	// Protect names of built-ins, TryRegister and Unregister deny them, Register logs overriding
+	Protect(...string)
+	// List return names of registered operations
+	List() []string
 }
 
 func GetOperationFactory() OperationFactory {
@@ -50,21 +66,55 @@ func GetOperationFactory() OperationFactory {
 
 // stdOperationFactory is default OperationFactory
 type stdOperationFactory struct {
-	operations map[string]Operation
+	registry *registry
+
+	// search operation in parent if not found
+	parent *stdOperationFactory
+}
+
+func newStdOperationFactory() *stdOperationFactory {
+	return &stdOperationFactory{
+		registry: newRegistry("operation"),
+	}
 }
 
 func (f *stdOperationFactory) Get(name string) Operation {
-	if value, ok := f.operations[name]; ok {
-		return value
+	if value, ok := f.registry.get(name); ok {
+		return value.(Operation)
+	}
+
+	if f.parent != nil {
+		return f.parent.Get(name)
 	}
 
 	return nil
 }
 
 func (f *stdOperationFactory) Register(op Operation, names ...string) {
-	for _, name := range names {
-		f.operations[name] = op
+	f.registry.register(op, names)
+}
+
+func (f *stdOperationFactory) TryRegister(op Operation, names ...string) error {
+	return f.registry.tryRegister(op, names, func(name string) bool {
+		return f.parent != nil && f.parent.Get(name) != nil
+	})
+}
+
+func (f *stdOperationFactory) Unregister(names ...string) {
+	f.registry.unregister(names)
+}
+
+func (f *stdOperationFactory) Protect(names ...string) {
+	f.registry.protect(names)
+}
+
+func (f *stdOperationFactory) List() []string {
+	var parent []string
+	if f.parent != nil {
+		parent = f.parent.List()
 	}
+
+	return f.registry.list(parent)
 }
 
 //----------------------------------------------------------------------------------
@@ -339,3 +389,74 @@ type NoneOperation struct {
 func (o *NoneOperation) Run(ctx *Context, variable Variable, value interface{}) bool {
 	return !o.AnyOperation.Run(ctx, variable, value)
 }
+
+//----------------------------------------------------------------------------------
+// BUCKET_COUNT is count of buckets that values are hashed into by bucket operation
+const BUCKET_COUNT = 10000
+
+// Bucket return bucket of value in [0, BUCKET_COUNT), hashed with murmur3.
+// The same value and salt always get the same bucket, different salts distribute values independently.
+func Bucket(salt string, value interface{}) int {
+	return int(HashID(salt+":"+itype.String(value)) % BUCKET_COUNT)
+}
+
+// BucketOperation check if bucket of variable value is in percentage range [from, to),
+// e.g. 10% of users in experiment exp1:
+//  ["ctx.uid", "bucket", {"salt": "exp1", "range": [0, 10]}]
+// Percentage has precision of 0.01, salt is optional.
+// False if variable has no value.
+type BucketOperation struct{ stringer }
+
+type bucketRange struct {
+	salt     string
+	from, to int
+}
+
+func (o *BucketOperation) Run(ctx *Context, variable Variable, value interface{}) bool {
+	cmpValue := GetVariableValue(ctx, variable)
+	if cmpValue == nil {
+		return false
+	}
+
+	r := value.(*bucketRange)
+	bucket := Bucket(r.salt, cmpValue)
+
+	return bucket >= r.from && bucket < r.to
+}
+
+func (o *BucketOperation) PrepareValue(value interface{}) (interface{}, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("[bucket] operation value must be a map with salt and range")
+	}
+
+	r := &bucketRange{}
+	for key, val := range m {
+		switch key {
+		case "salt":
+			if r.salt, ok = val.(string); !ok {
+				return nil, errors.New("[bucket] operation salt must be a string")
+			}
+		case "range":
+			bounds := ToArray(val)
+			if len(bounds) != 2 || itype.GetType(bounds[0]) != itype.NUMBER || itype.GetType(bounds[1]) != itype.NUMBER {
+				return nil, errors.New("[bucket] operation range must be a list with 2 numbers")
+			}
+			from, to := itype.Float(bounds[0]), itype.Float(bounds[1])
+			if from < 0 || to > 100 || from > to {
+				return nil, errors.New("[bucket] operation range must be in [0, 100] and from <= to")
+			}
+			// percentage to bucket
+			r.from = int(math.Round(from * BUCKET_COUNT / 100))
+			r.to = int(math.Round(to * BUCKET_COUNT / 100))
+		default:
+			return nil, errors.New(fmt.Sprintf("[bucket] operation unknown option[%s]", key))
+		}
+	}
+
+	if _, ok := m["range"]; !ok {
+		return nil, errors.New("[bucket] operation range is required")
+	}
+
+	return r, nil
+}
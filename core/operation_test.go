@@ -127,6 +127,45 @@ func (s *OperationTestSuite) TestListMatch() {
 	s.testCases(s.getOppositeCases(tests, map[string]string{"any": "none", "none": "any"}))
 }
 
+func (s *OperationTestSuite) TestBucket() {
+	bucket := Bucket("exp1", 25)
+	percent := float64(bucket) / 100
+	tests := []opTestCase{
+		{[]interface{}{"data.age", "bucket", map[string]interface{}{"salt": "exp1", "range": []interface{}{0, 100}}}, true, false},
+		{[]interface{}{"data.age", "bucket", map[string]interface{}{"salt": "exp1", "range": []interface{}{percent, percent + 0.01}}}, true, false},
+		{[]interface{}{"data.age", "bucket", map[string]interface{}{"salt": "exp1", "range": []interface{}{0, percent}}}, false, false},
+		{[]interface{}{"data.age", "bucket", map[string]interface{}{"salt": "exp1", "range": []interface{}{50, 50}}}, false, false},
+		{[]interface{}{"data.none", "bucket", map[string]interface{}{"range": []interface{}{0, 100}}}, false, false},
+		{[]interface{}{"data.age", "bucket", map[string]interface{}{"salt": "exp1"}}, false, true},
+		{[]interface{}{"data.age", "bucket", map[string]interface{}{"range": []interface{}{10, 0}}}, false, true},
+		{[]interface{}{"data.age", "bucket", map[string]interface{}{"range": []interface{}{0, 101}}}, false, true},
+		{[]interface{}{"data.age", "bucket", map[string]interface{}{"range": "0,10"}}, false, true},
+		{[]interface{}{"data.age", "bucket", map[string]interface{}{"range": []interface{}{0, 10}, "foo": 1}}, false, true},
+		{[]interface{}{"data.age", "bucket", "0,10"}, false, true},
+	}
+
+	s.testCases(tests)
+}
+
+func TestBucket(t *testing.T) {
+	hit := make(map[bool]int)
+	for uid := 0; uid < 10000; uid++ {
+		require.Equal(t, Bucket("exp1", uid), Bucket("exp1", uid))
+		hit[Bucket("exp1", uid) < 1000] += 1
+	}
+	// about 10% in [0, 1000)
+	require.True(t, hit[true] > 800 && hit[true] < 1200, "hit:%v", hit)
+
+	same := 0
+	for uid := 0; uid < 10000; uid++ {
+		if (Bucket("exp1", uid) < 5000) == (Bucket("exp2", uid) < 5000) {
+			same++
+		}
+	}
+	// experiments are independent
+	require.True(t, same > 4500 && same < 5500, "same:%d", same)
+}
+
 func (s *OperationTestSuite) getOppositeCases(tests []opTestCase, oppositeOpMap map[string]string) []opTestCase {
 	cases := make([]opTestCase, 0, len(tests))
 	for _, c := range tests {
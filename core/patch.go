@@ -0,0 +1,99 @@
+package core
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is operation of RFC 6902 JSON Patch, Filter is name of filter that makes the change.
+type PatchOperation struct {
+	Op     string // add, remove, replace
+	Path   string
+	Value  interface{}
+	Filter string
+}
+
+// MarshalJSON output: {"op":..,"path":..,"value":..,"filter":..}, value is omitted for remove operation.
+func (o *PatchOperation) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"op":   o.Op,
+		"path": o.Path,
+	}
+	if o.Op != "remove" {
+		m["value"] = o.Value
+	}
+	if o.Filter != "" {
+		m["filter"] = o.Filter
+	}
+
+	return json.Marshal(m)
+}
+
+var _pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Diff return JSON Patch operations that turn before into after.
+// Maps are compared key by key, changed or removed keys come first in key order, then added keys in key order.
+// Lists with different length are replaced as a whole.
+func Diff(before, after interface{}) []*PatchOperation {
+	ops := make([]*PatchOperation, 0)
+	diff("", before, after, &ops)
+
+	return ops
+}
+
+func diff(path string, before, after interface{}, ops *[]*PatchOperation) {
+	switch b := before.(type) {
+	case map[string]interface{}:
+		if a, ok := after.(map[string]interface{}); ok {
+			diffMap(path, b, a, ops)
+			return
+		}
+	case []interface{}:
+		if a, ok := after.([]interface{}); ok && len(a) == len(b) {
+			for i := range b {
+				diff(path+"/"+strconv.Itoa(i), b[i], a[i], ops)
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		*ops = append(*ops, &PatchOperation{Op: "replace", Path: path, Value: Clone(after)})
+	}
+}
+
+func diffMap(path string, before, after map[string]interface{}, ops *[]*PatchOperation) {
+	keys := make([]string, 0, len(before))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		kpath := path + "/" + _pointerEscaper.Replace(key)
+		if value, ok := after[key]; ok {
+			diff(kpath, before[key], value, ops)
+		} else {
+			*ops = append(*ops, &PatchOperation{Op: "remove", Path: kpath})
+		}
+	}
+
+	keys = keys[:0]
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		*ops = append(*ops, &PatchOperation{
+			Op:    "add",
+			Path:  path + "/" + _pointerEscaper.Replace(key),
+			Value: Clone(after[key]),
+		})
+	}
+}
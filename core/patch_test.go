@@ -0,0 +1,52 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	before := map[string]interface{}{
+		"a":   1,
+		"b":   map[string]interface{}{"c": 1, "d": 2},
+		"l":   []interface{}{1, 2},
+		"l2":  []interface{}{1},
+		"x/y": "z",
+		"del": true,
+	}
+	after := map[string]interface{}{
+		"a":   false,
+		"b":   map[string]interface{}{"c": 1, "e": map[string]interface{}{"f": 1}},
+		"l":   []interface{}{1, 3},
+		"l2":  []interface{}{1, 2},
+		"x/y": "z~",
+		"new": nil,
+	}
+
+	b, err := json.Marshal(Diff(before, after))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op":"replace","path":"/a","value":false},
+		{"op":"remove","path":"/b/d"},
+		{"op":"add","path":"/b/e","value":{"f":1}},
+		{"op":"remove","path":"/del"},
+		{"op":"replace","path":"/l/1","value":3},
+		{"op":"replace","path":"/l2","value":[1,2]},
+		{"op":"replace","path":"/x~1y","value":"z~"},
+		{"op":"add","path":"/new","value":null}
+	]`, string(b))
+
+	assert.Empty(t, Diff(before, before))
+
+	ops := Diff(1, 2)
+	require.Len(t, ops, 1)
+	assert.Equal(t, &PatchOperation{Op: "replace", Path: "", Value: 2}, ops[0])
+
+	// value is copied
+	ops = Diff(map[string]interface{}{}, after)
+	after["b"].(map[string]interface{})["c"] = 2
+	assert.Equal(t, 1, ops[1].Value.(map[string]interface{})["c"])
+}
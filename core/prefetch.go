@@ -0,0 +1,145 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPrefetchTimeout is reported when evaluation waits for variable being prefetched longer than the prefetch timeout
+var ErrPrefetchTimeout = errors.New("prefetch timeout")
+
+// ConditionVariables return variables referenced by condition, in order of first appearance.
+func ConditionVariables(c Condition) []Variable {
+	variables := make([]Variable, 0)
+	seen := make(map[string]bool)
+
+	var walk func(Condition)
+	walk = func(c Condition) {
+		switch c := c.(type) {
+		case *StdCondition:
+			for _, v := range append([]Variable{c.Variable()}, c.OperandVariables()...) {
+				if v == nil || seen[v.Name()] {
+					continue
+				}
+				seen[v.Name()] = true
+				variables = append(variables, v)
+				// variables of named condition
+				if cv, ok := v.(*conditionVariable); ok {
+					walk(cv.named.condition)
+				}
+			}
+		case *conditionRef:
+			walk(c.named.condition)
+		case *ConditionGroup:
+			for _, sub := range c.Conditions() {
+				walk(sub)
+			}
+		}
+	}
+	walk(c)
+
+	return variables
+}
+
+// Prefetch resolve cacheable variables concurrently into ctx.Cache(), so evaluation gets them from cache.
+// It waits each variable at most timeout(no limit if timeout <= 0).
+// Variable that is not resolved in timeout is not resolved again by evaluation, evaluation waits for it
+// at most timeout too, then the condition reports error ErrPrefetchTimeout.
+// Variables that are not cacheable or cached already are skipped.
+func Prefetch(ctx *Context, variables []Variable, timeout time.Duration) {
+	cache := ctx.Cache()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		timeouts []string
+	)
+	for _, v := range variables {
+		if v == nil || !v.Cacheable() {
+			continue
+		}
+		if _, ok := cache.Load(v.Name()); ok {
+			continue
+		}
+
+		// evaluation waits for the call instead of resolving variable again
+		call := &prefetchCall{done: make(chan struct{}), timeout: timeout}
+		cache.Store(v.Name(), call)
+
+		wg.Add(1)
+		go func(v Variable, call *prefetchCall) {
+			defer wg.Done()
+
+			vctx := ctx
+			if timeout > 0 {
+				tctx, cancel := context.WithTimeout(ctx, timeout)
+				defer cancel()
+				vctx = WithContext(tctx)
+			}
+
+			go call.resolve(vctx, cache, v)
+
+			select {
+			case <-call.done:
+			case <-vctx.Done():
+				mu.Lock()
+				timeouts = append(timeouts, v.Name())
+				mu.Unlock()
+			}
+		}(v, call)
+	}
+
+	wg.Wait()
+
+	// trace is not concurrency-safe
+	if trace := ctx.Trace(); trace != nil && len(timeouts) > 0 {
+		trace.Log("PREFETCH TIMEOUT", timeouts)
+	}
+}
+
+// prefetchCall is variable being resolved by Prefetch, it's stored in cache until the value is resolved
+type prefetchCall struct {
+	done    chan struct{}
+	timeout time.Duration
+	value   interface{}
+	err     error
+}
+
+// resolve variable without cache, error is reported when condition gets the value from call
+func (c *prefetchCall) resolve(ctx *Context, cache Cache, v Variable) {
+	defer close(c.done)
+
+	if observer := ctx.Observer(); observer != nil {
+		start := time.Now()
+		defer func() {
+			observer.ObserveVariable(v.Name(), false, time.Since(start))
+		}()
+	}
+
+	c.value, c.err = resolveVariable(ctx, v)
+	if c.err == nil {
+		cache.Store(v.Name(), c.value)
+	}
+}
+
+// wait return value of call, or error if it's not resolved in timeout or ctx is done
+func (c *prefetchCall) wait(ctx *Context, name string) (interface{}, error) {
+	var timeout <-chan time.Time
+	if c.timeout > 0 {
+		timer := time.NewTimer(c.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-timeout:
+		return nil, errors.Wrapf(ErrPrefetchTimeout, "Variable[%s] is not resolved in %s", name, c.timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
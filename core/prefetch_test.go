@@ -0,0 +1,94 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// barrierValue returns true only if all values of the barrier are resolving at the same time
+func barrierValue(barrier *sync.WaitGroup) ValueFunc {
+	return func(_ *Context) interface{} {
+		barrier.Done()
+		done := make(chan struct{})
+		go func() {
+			barrier.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return true
+		case <-time.After(time.Second):
+			return false
+		}
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	var barrier sync.WaitGroup
+	barrier.Add(2)
+
+	e := NewEngine(InheritDefault())
+	e.VariableFactory().Register(SingletonVariableCreator(NewSimpleVariable("v1", Cacheable, barrierValue(&barrier))), "v1")
+	e.VariableFactory().Register(SingletonVariableCreator(NewSimpleVariable("v2", Cacheable, barrierValue(&barrier))), "v2")
+	e.VariableFactory().Register(SingletonVariableCreator(NewSimpleVariable("slow", Cacheable, ValueFunc(func(_ *Context) interface{} {
+		time.Sleep(100 * time.Millisecond)
+		return 1
+	}))), "slow")
+	calls := 0
+	e.VariableFactory().Register(SingletonVariableCreator(NewSimpleVariable("uncacheable", Uncacheable, ValueFunc(func(_ *Context) interface{} {
+		calls++
+		return 1
+	}))), "uncacheable")
+
+	cond, err := e.NewCondition([]interface{}{
+		[]interface{}{"v1", "=", true},
+		[]interface{}{"any?", "=>", []interface{}{
+			[]interface{}{"v2", "=", true},
+			[]interface{}{"v1", "=", true},
+			[]interface{}{"uncacheable", "=", 1},
+		}},
+	}, LOGIC_ALL)
+	require.NoError(t, err)
+
+	variables := ConditionVariables(cond)
+	names := make([]string, len(variables))
+	for i, v := range variables {
+		names[i] = v.Name()
+	}
+	assert.Equal(t, []string{"v1", "v2", "uncacheable"}, names)
+
+	ctx := NewContext()
+	Prefetch(ctx, variables, 0)
+	v1, ok := ctx.Cache().Load("v1")
+	assert.True(t, ok)
+	assert.Equal(t, true, v1, "resolved concurrently")
+	_, ok = ctx.Cache().Load("v2")
+	assert.True(t, ok)
+	assert.Equal(t, 0, calls, "uncacheable variable is skipped")
+	assert.True(t, cond.Success(ctx))
+
+	// wait at most timeout
+	ctx = NewContext()
+	start := time.Now()
+	Prefetch(ctx, []Variable{e.VariableFactory().Create("slow")}, 10*time.Millisecond)
+	assert.Less(t, int64(time.Since(start)), int64(90*time.Millisecond))
+
+	// evaluation waits at most timeout for variable being prefetched, without resolving it again
+	collector := NewErrorCollector(ERROR_AS_FALSE)
+	ctx = WithContext(ctx, WithErrorCollector(collector))
+	slow, err := e.NewCondition([]interface{}{"slow", "=", 1}, LOGIC_ALL)
+	require.NoError(t, err)
+	assert.False(t, slow.Success(ctx))
+	assert.Less(t, int64(time.Since(start)), int64(90*time.Millisecond))
+	require.Equal(t, 1, collector.Len())
+	assert.True(t, errors.Is(collector.Errors()[0], ErrPrefetchTimeout), "%v", collector.Errors()[0])
+
+	// value resolved later is cached
+	time.Sleep(120 * time.Millisecond)
+	assert.True(t, slow.Success(ctx))
+}
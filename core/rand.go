@@ -0,0 +1,63 @@
+package core
+
+import (
+	"math/rand"
+
+	"github.com/techxmind/go-utils/itype"
+)
+
+// Rand is source of random numbers used by ranking and probability choices, *rand.Rand implements it.
+// Set it with context option WithRand to make choices reproducible.
+type Rand interface {
+	// Int63n return non-negative random number in [0,n)
+	Int63n(n int64) int64
+}
+
+// globalRand use the top-level functions of math/rand, it's concurrency-safe
+type globalRand struct{}
+
+func (globalRand) Int63n(n int64) int64 {
+	return rand.Int63n(n)
+}
+
+// HashRand return Rand seeded with hash of key, the same key always produces the same sequence.
+// It's cheap to create for every choice, but not concurrency-safe.
+func HashRand(key string) Rand {
+	return &hashRand{state: HashID(key)}
+}
+
+// hashRand is splitmix64 generator
+type hashRand struct {
+	state uint64
+}
+
+func (r *hashRand) Int63n(n int64) int64 {
+	if n <= 0 {
+		panic("invalid argument to Int63n")
+	}
+
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+
+	return int64((z >> 1) % uint64(n))
+}
+
+// StickyRand return Rand derived from value of variable and salt, so choices are stable for the same value,
+// e.g. the same user always gets the same banner with variable ctx.uid.
+// Different salts make choices of different experiments independent.
+// Return ctx.Rand() if variable is nil or has no value.
+func StickyRand(ctx *Context, v Variable, salt string) Rand {
+	if v == nil {
+		return ctx.Rand()
+	}
+
+	value := GetVariableValue(ctx, v)
+	if value == nil {
+		return ctx.Rand()
+	}
+
+	return HashRand(salt + ":" + itype.String(value))
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/techxmind/go-utils/itype"
+)
+
+func TestRand(t *testing.T) {
+	ctx := NewContext()
+	assert.Equal(t, globalRand{}, ctx.Rand())
+
+	values := func() []interface{} {
+		ctx := WithContext(context.Background(), WithRand(rand.New(rand.NewSource(1))))
+		v := _variableFactory.Create("rand")
+		return []interface{}{v.Value(ctx), v.Value(ctx), v.Value(ctx)}
+	}
+	assert.Equal(t, values(), values())
+}
+
+func TestStickyRand(t *testing.T) {
+	ctx := NewContext()
+	v := _variableFactory.Create("ctx.uid")
+
+	assert.Equal(t, globalRand{}, StickyRand(ctx, v, "salt"), "no value")
+	assert.Equal(t, globalRand{}, StickyRand(ctx, nil, "salt"), "no variable")
+
+	ctx.Set("uid", 100)
+	n := StickyRand(ctx, v, "salt").Int63n(1 << 40)
+	assert.Equal(t, n, StickyRand(ctx, v, "salt").Int63n(1<<40))
+	assert.NotEqual(t, n, StickyRand(ctx, v, "salt2").Int63n(1<<40))
+
+	// choices are uniform
+	hit := make([]int, 4)
+	for i := 0; i < 4000; i++ {
+		r := HashRand(itype.String(i))
+		hit[r.Int63n(4)]++
+	}
+	for _, n := range hit {
+		assert.InDelta(t, 1000, n, 150, "%v", hit)
+	}
+	// no source of math/rand is allocated
+	assert.LessOrEqual(t, testing.AllocsPerRun(10, func() {
+		HashRand("uid").Int63n(10)
+	}), 1.0)
+}
@@ -0,0 +1,87 @@
+package core
+
+// ConditionResult is evaluation detail of condition.
+// For condition group, Logic and Conditions are set, conditions skipped by short-circuit evaluation are not included.
+// For condition that references library definitions, Conditions are results of the definitions.
+type ConditionResult struct {
+	Expr       string             `json:"expr"`
+	Logic      string             `json:"logic,omitempty"`
+	Variable   string             `json:"variable,omitempty"`
+	Value      interface{}        `json:"value,omitempty"` // resolved variable value
+	Operation  string             `json:"operation,omitempty"`
+	Operand    interface{}        `json:"operand,omitempty"` // operation value in definition
+	Succ       bool               `json:"succ"`
+	Conditions []*ConditionResult `json:"conditions,omitempty"`
+}
+
+// ExecutorResult is detail of executor that ran
+type ExecutorResult struct {
+	Expr       string      `json:"expr"`
+	Key        string      `json:"key"`
+	Assignment string      `json:"assignment"`
+	Value      interface{} `json:"value"` // assignment value in definition
+}
+
+// Recorder records structured evaluation details of conditions and executors.
+// It's not concurrency-safe, use one recorder for each run.
+type Recorder struct {
+	Condition *ConditionResult
+	Executors []*ExecutorResult
+
+	stack []*ConditionResult
+}
+
+func (r *Recorder) addCondition(result *ConditionResult) {
+	if n := len(r.stack); n > 0 {
+		parent := r.stack[n-1]
+		parent.Conditions = append(parent.Conditions, result)
+	} else {
+		r.Condition = result
+	}
+}
+
+func (r *Recorder) enterGroup(result *ConditionResult) {
+	r.addCondition(result)
+	r.stack = append(r.stack, result)
+}
+
+func (r *Recorder) leaveGroup() {
+	if n := len(r.stack); n > 0 {
+		r.stack = r.stack[:n-1]
+	}
+}
+
+// enterScope collect condition results separately until leaveScope,
+// e.g. results of library conditions evaluated as variable value are children of the referencing condition.
+func (r *Recorder) enterScope() {
+	r.stack = append(r.stack, &ConditionResult{})
+}
+
+// leaveScope return condition results collected in scope
+func (r *Recorder) leaveScope() []*ConditionResult {
+	n := len(r.stack)
+	if n == 0 {
+		return nil
+	}
+	scope := r.stack[n-1]
+	r.stack = r.stack[:n-1]
+
+	return scope.Conditions
+}
+
+func (r *Recorder) addExecutor(result *ExecutorResult) {
+	r.Executors = append(r.Executors, result)
+}
+
+// resolvedVariable wrap variable with resolved value,
+// so the recorded value is exactly the one operation compares with.
+type resolvedVariable struct {
+	Variable
+	value interface{}
+}
+
+func (v *resolvedVariable) Cacheable() bool              { return false }
+func (v *resolvedVariable) Value(_ *Context) interface{} { return v.value }
+func (v *resolvedVariable) ValueE(_ *Context) (interface{}, error) {
+	return v.value, nil
+}
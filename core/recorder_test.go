@@ -0,0 +1,68 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := &Recorder{}
+	ctx := WithContext(NewContext(), WithRecorder(recorder))
+
+	cond, err := NewCondition([]interface{}{
+		[]interface{}{"succ", "=", true},
+		[]interface{}{"none?", "=>", []interface{}{
+			[]interface{}{"succ", "=", false},
+		}},
+	}, LOGIC_ALL)
+	require.NoError(t, err)
+	assert.True(t, cond.Success(ctx))
+
+	require.NotNil(t, recorder.Condition)
+	assert.Equal(t, "all?", recorder.Condition.Logic)
+	assert.True(t, recorder.Condition.Succ)
+	require.Equal(t, 2, len(recorder.Condition.Conditions))
+	assert.Equal(t, true, recorder.Condition.Conditions[0].Value)
+	assert.Equal(t, "none?", recorder.Condition.Conditions[1].Logic)
+	assert.Equal(t, false, recorder.Condition.Conditions[1].Conditions[0].Succ)
+
+	executor, err := NewExecutor([]interface{}{"a", "=", 1})
+	require.NoError(t, err)
+	executor.Execute(ctx, map[string]interface{}{})
+	require.Equal(t, 1, len(recorder.Executors))
+	assert.Equal(t, &ExecutorResult{Expr: "a = 1", Key: "a", Assignment: "=", Value: 1}, recorder.Executors[0])
+
+	// library definitions are children of the referencing conditions
+	e := NewEngine(InheritDefault())
+	require.NoError(t, e.ConditionLibrary().Define(map[string]interface{}{
+		"is_vip": []interface{}{
+			[]interface{}{"succ", "=", true},
+			[]interface{}{"ctx.level", ">=", 3},
+		},
+	}))
+	cond, err = e.NewCondition([]interface{}{
+		[]interface{}{"@is_vip", "=", false},
+		[]interface{}{"use?", "=", "is_vip"},
+	}, LOGIC_ANY)
+	require.NoError(t, err)
+	recorder = &Recorder{}
+	ctx = WithContext(NewContext(), WithRecorder(recorder))
+	ctx.Set("level", 3)
+	assert.True(t, cond.Success(ctx))
+
+	require.Equal(t, 2, len(recorder.Condition.Conditions))
+	variable := recorder.Condition.Conditions[0]
+	assert.Equal(t, "@is_vip", variable.Variable)
+	assert.Equal(t, true, variable.Value)
+	require.Equal(t, 1, len(variable.Conditions))
+	assert.Equal(t, "all?", variable.Conditions[0].Logic)
+	assert.Equal(t, 2, len(variable.Conditions[0].Conditions))
+
+	ref := recorder.Condition.Conditions[1]
+	assert.Equal(t, "is_vip", ref.Operand)
+	assert.True(t, ref.Succ)
+	require.Equal(t, 1, len(ref.Conditions))
+	assert.Equal(t, "all?", ref.Conditions[0].Logic)
+}
@@ -0,0 +1,437 @@
+package core
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// GetValue get value of object by key path, e.g. user.tags.0
+// Besides map[string]interface{} and []interface{}, object can be struct, pointer, typed map, slice and array.
+// Struct field is matched with name in `filter` tag, then `json` tag, then field name.
+// Getter on the path gets the rest of key path.
+func GetValue(obj interface{}, keyPath string) (interface{}, bool) {
+	if keyPath == "" {
+		return obj, true
+	}
+
+	keys := strings.Split(keyPath, ".")
+	for i, key := range keys {
+		if getter, ok := obj.(Getter); ok {
+			if value, ok := getter.AssignmentGet(strings.Join(keys[i:], ".")); ok {
+				return value, true
+			}
+		}
+
+		switch v := obj.(type) {
+		case map[string]interface{}:
+			value, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			obj = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			obj = v[index]
+		default:
+			child, err := childValue(reflect.ValueOf(obj), key, false)
+			if err != nil || !child.IsValid() {
+				return nil, false
+			}
+			obj = interfaceValue(child)
+		}
+	}
+
+	return obj, true
+}
+
+// interfaceValue return value for operations, named basic types are converted to builtin types
+func interfaceValue(v reflect.Value) interface{} {
+	v = indirect(v)
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+
+	if t, ok := _basicTypes[v.Kind()]; ok && v.Type() != t {
+		return v.Convert(t).Interface()
+	}
+
+	return v.Interface()
+}
+
+var _basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeOf(false),
+	reflect.String:  reflect.TypeOf(""),
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+}
+
+// indirect dereference pointers and interfaces, invalid value is returned for nil
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
+
+// indirectCreate is indirect that allocates nil pointer and map if v is settable
+func indirectCreate(v reflect.Value) reflect.Value {
+	for v.IsValid() {
+		switch v.Kind() {
+		case reflect.Ptr:
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		case reflect.Interface:
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		case reflect.Map:
+			if v.IsNil() && v.CanSet() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			return v
+		default:
+			return v
+		}
+	}
+
+	return v
+}
+
+// childValue return child of container v by key, invalid value if not exists.
+// If create is true, missing map[string]interface{} nodes, nil pointers and nil maps are created.
+func childValue(v reflect.Value, key string, create bool) (reflect.Value, error) {
+	if create {
+		v = indirectCreate(v)
+	} else {
+		v = indirect(v)
+	}
+
+	if !v.IsValid() {
+		return reflect.Value{}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		mkey, err := mapKey(key, v.Type().Key())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		child := v.MapIndex(mkey)
+		if !child.IsValid() && create && !v.IsNil() {
+			if v.Type().Elem().Kind() == reflect.Interface {
+				child = reflect.ValueOf(make(map[string]interface{}))
+				v.SetMapIndex(mkey, child)
+			} else if elem := v.Type().Elem(); elem.Kind() == reflect.Map || elem.Kind() == reflect.Ptr {
+				if elem.Kind() == reflect.Map {
+					child = reflect.MakeMap(elem)
+				} else {
+					child = reflect.New(elem.Elem())
+				}
+				v.SetMapIndex(mkey, child)
+			}
+		}
+		return child, nil
+	case reflect.Struct:
+		if index, ok := structFields(v.Type())[key]; ok {
+			return v.FieldByIndex(index), nil
+		}
+	case reflect.Slice, reflect.Array:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < v.Len() {
+			return v.Index(i), nil
+		}
+	}
+
+	return reflect.Value{}, nil
+}
+
+func mapKey(key string, t reflect.Type) (reflect.Value, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(key).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, err := strconv.ParseInt(key, 10, 64); err == nil {
+			return reflect.ValueOf(i).Convert(t), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i, err := strconv.ParseUint(key, 10, 64); err == nil {
+			return reflect.ValueOf(i).Convert(t), nil
+		}
+	case reflect.Interface:
+		return reflect.ValueOf(key), nil
+	}
+
+	return reflect.Value{}, errors.Errorf("Key[%s] can not be converted to map key type %s", key, t)
+}
+
+// _structFields caches struct type => field key => field index
+var _structFields sync.Map
+
+func structFields(t reflect.Type) map[string][]int {
+	if fields, ok := _structFields.Load(t); ok {
+		return fields.(map[string][]int)
+	}
+
+	fields := make(map[string][]int)
+	collectStructFields(t, nil, fields)
+	_structFields.Store(t, fields)
+
+	return fields
+}
+
+func collectStructFields(t reflect.Type, index []int, fields map[string][]int) {
+	embedded := make([]reflect.StructField, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("filter"); ok {
+			name = strings.Split(tag, ",")[0]
+		} else if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag = strings.Split(tag, ",")[0]; tag != "" {
+				name = tag
+			}
+		} else if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			// fields of embedded struct are promoted
+			embedded = append(embedded, field)
+			continue
+		}
+
+		if name == "-" || field.PkgPath != "" {
+			continue
+		}
+
+		fields[name] = append(append([]int{}, index...), i)
+	}
+
+	// fields of outer struct take precedence
+	for _, field := range embedded {
+		promoted := make(map[string][]int)
+		collectStructFields(field.Type, append(append([]int{}, index...), field.Index...), promoted)
+		for name, idx := range promoted {
+			if _, ok := fields[name]; !ok {
+				fields[name] = idx
+			}
+		}
+	}
+}
+
+// convertValue convert value to type t
+func convertValue(value interface{}, t reflect.Type) (reflect.Value, error) {
+	if value == nil {
+		return reflect.Zero(t), nil
+	}
+
+	v := reflect.ValueOf(value)
+
+	if t.Kind() == reflect.Interface {
+		if !v.Type().Implements(t) {
+			return reflect.Value{}, errors.Errorf("Value of type %T does not implement %s", value, t)
+		}
+		if IsScalar(value) {
+			return v, nil
+		}
+		return reflect.ValueOf(Clone(value)), nil
+	}
+
+	if v.Type().AssignableTo(t) {
+		if IsScalar(value) {
+			return v, nil
+		}
+		return reflect.ValueOf(Clone(value)), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem, err := convertValue(value, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case reflect.Bool, reflect.String:
+		if v.Kind() == t.Kind() {
+			return v.Convert(t), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return convertNumber(value, v, t)
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		// e.g. map[string]interface{} from definition to struct
+		b, err := json.Marshal(value)
+		if err != nil {
+			return reflect.Value{}, errors.Wrapf(err, "Value of type %T can not be converted to %s", value, t)
+		}
+		ptr := reflect.New(t)
+		if err := json.Unmarshal(b, ptr.Interface()); err != nil {
+			return reflect.Value{}, errors.Errorf("Value %s can not be converted to %s: %v", b, t, err)
+		}
+		return ptr.Elem(), nil
+	}
+
+	return reflect.Value{}, errors.Errorf("Value of type %T can not be converted to %s", value, t)
+}
+
+func convertNumber(value interface{}, v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	var f float64
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Convert(t), nil
+	case reflect.Float32, reflect.Float64:
+		f = v.Float()
+	default:
+		return reflect.Value{}, errors.Errorf("Value of type %T can not be converted to %s", value, t)
+	}
+
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Convert(t), nil
+	}
+
+	// float from json definition, e.g. 3.0 to int
+	if f != float64(int64(f)) {
+		return reflect.Value{}, errors.Errorf("Value %v can not be converted to %s without losing precision", value, t)
+	}
+	if (t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64) && f < 0 {
+		return reflect.Value{}, errors.Errorf("Value %v can not be converted to %s", value, t)
+	}
+
+	return reflect.ValueOf(int64(f)).Convert(t), nil
+}
+
+// walkPath return the container at key path, missing nodes are created if create is true
+func walkPath(data interface{}, keys []string, create bool) (reflect.Value, error) {
+	v := reflect.ValueOf(data)
+
+	for _, key := range keys {
+		child, err := childValue(v, key, create)
+		if err != nil || !child.IsValid() {
+			return reflect.Value{}, err
+		}
+		v = child
+	}
+
+	return v, nil
+}
+
+// setValue set value of key in container
+func setValue(container reflect.Value, key string, value interface{}) error {
+	container = indirectCreate(container)
+	if !container.IsValid() {
+		return nil
+	}
+
+	switch container.Kind() {
+	case reflect.Map:
+		if container.IsNil() {
+			return errors.Errorf("Can not set key[%s] of nil %s", key, container.Type())
+		}
+		mkey, err := mapKey(key, container.Type().Key())
+		if err != nil {
+			return err
+		}
+		mvalue, err := convertValue(value, container.Type().Elem())
+		if err != nil {
+			return errors.Wrapf(err, "Set key[%s]", key)
+		}
+		container.SetMapIndex(mkey, mvalue)
+	case reflect.Struct:
+		index, ok := structFields(container.Type())[key]
+		if !ok {
+			return errors.Errorf("Field[%s] not found in %s", key, container.Type())
+		}
+		field := container.FieldByIndex(index)
+		if !field.CanSet() {
+			return errors.Errorf("Field[%s] of %s can not be set, use pointer of struct", key, container.Type())
+		}
+		fvalue, err := convertValue(value, field.Type())
+		if err != nil {
+			return errors.Wrapf(err, "Set field[%s] of %s", key, container.Type())
+		}
+		field.Set(fvalue)
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= container.Len() {
+			return nil
+		}
+		elem := container.Index(i)
+		if !elem.CanSet() {
+			return errors.Errorf("Index[%d] of %s can not be set", i, container.Type())
+		}
+		evalue, err := convertValue(value, elem.Type())
+		if err != nil {
+			return errors.Wrapf(err, "Set index[%d] of %s", i, container.Type())
+		}
+		elem.Set(evalue)
+	default:
+		return errors.Errorf("Can not set key[%s] of %s", key, container.Type())
+	}
+
+	return nil
+}
+
+// deleteValue delete key from map, or set struct field to zero value
+func deleteValue(container reflect.Value, key string) error {
+	container = indirect(container)
+	if !container.IsValid() {
+		return nil
+	}
+
+	switch container.Kind() {
+	case reflect.Map:
+		mkey, err := mapKey(key, container.Type().Key())
+		if err != nil {
+			return err
+		}
+		container.SetMapIndex(mkey, reflect.Value{})
+	case reflect.Struct:
+		index, ok := structFields(container.Type())[key]
+		if !ok {
+			return nil
+		}
+		field := container.FieldByIndex(index)
+		if !field.CanSet() {
+			return errors.Errorf("Field[%s] of %s can not be set, use pointer of struct", key, container.Type())
+		}
+		field.Set(reflect.Zero(field.Type()))
+	default:
+		return errors.Errorf("Can not delete key[%s] of %s", key, container.Type())
+	}
+
+	return nil
+}
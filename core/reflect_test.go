@@ -0,0 +1,211 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLevel string
+
+type testBase struct {
+	ID int64 `json:"id"`
+}
+
+type testProfile struct {
+	City string `json:"city"`
+}
+
+type testUser struct {
+	testBase
+	Name    string            `json:"name"`
+	Level   testLevel         `filter:"level" json:"lv"`
+	Score   float64           `json:"score,omitempty"`
+	Age     int               // field name
+	Tags    []string          `json:"tags"`
+	Attrs   map[string]int    `json:"attrs"`
+	Profile *testProfile      `json:"profile"`
+	Friends []testProfile     `json:"friends"`
+	Ignored string            `json:"-"`
+	Extra   map[string]string `json:"extra"`
+	secret  string
+}
+
+func TestGetValue(t *testing.T) {
+	user := &testUser{
+		testBase: testBase{ID: 1},
+		Name:     "foo",
+		Level:    "vip",
+		Age:      18,
+		Tags:     []string{"a", "b"},
+		Attrs:    map[string]int{"x": 1},
+		Profile:  &testProfile{City: "bj"},
+		Friends:  []testProfile{{City: "sh"}},
+		Ignored:  "ignored",
+		secret:   "secret",
+	}
+	data := map[string]interface{}{
+		"user":  user,
+		"ids":   map[int]string{1: "one"},
+		"plain": []interface{}{map[string]interface{}{"a": 1}},
+	}
+
+	tests := []struct {
+		key      string
+		expected interface{}
+		exists   bool
+	}{
+		{"user.id", int64(1), true},
+		{"user.name", "foo", true},
+		{"user.level", "vip", true},
+		{"user.lv", nil, false},
+		{"user.Age", 18, true},
+		{"user.tags.1", "b", true},
+		{"user.tags.2", nil, false},
+		{"user.attrs.x", 1, true},
+		{"user.attrs.y", nil, false},
+		{"user.profile.city", "bj", true},
+		{"user.friends.0.city", "sh", true},
+		{"user.Ignored", nil, false},
+		{"user.secret", nil, false},
+		{"ids.1", "one", true},
+		{"ids.a", nil, false},
+		{"plain.0.a", 1, true},
+	}
+
+	for _, c := range tests {
+		value, exists := GetValue(data, c.key)
+		assert.Equal(t, c.exists, exists, c.key)
+		assert.Equal(t, c.expected, value, c.key)
+	}
+
+	// nil pointer
+	user.Profile = nil
+	value, exists := GetValue(data, "user.profile.city")
+	assert.False(t, exists)
+	assert.Nil(t, value)
+
+	ctx := WithData(NewContext(), data)
+	cond, err := NewCondition([]interface{}{
+		[]interface{}{"data.user.level", "=", "vip"},
+		[]interface{}{"data.user.Age", ">=", 18},
+	}, LOGIC_ALL)
+	require.NoError(t, err)
+	assert.True(t, cond.Success(ctx))
+}
+
+func TestStructAssignment(t *testing.T) {
+	user := &testUser{}
+	data := map[string]interface{}{
+		"user":  user,
+		"value": testUser{},
+	}
+
+	collector := NewErrorCollector(ERROR_AS_FALSE)
+	ctx := WithContext(NewContext(), WithErrorCollector(collector))
+
+	run := func(item ...interface{}) []*EvalError {
+		executor, err := NewExecutor(item)
+		require.NoError(t, err)
+		n := collector.Len()
+		executor.Execute(ctx, data)
+		return collector.Since(n)
+	}
+
+	assert.Empty(t, run("user.name", "=", "bar"))
+	assert.Empty(t, run("user.level", "=", "vip"))
+	assert.Empty(t, run("user.Age", "=", 20.0))
+	assert.Empty(t, run("user.score", "=", 1))
+	assert.Empty(t, run("user.tags", "=", []interface{}{"a", "b"}))
+	assert.Empty(t, run("user.tags.1", "=", "c"))
+	assert.Empty(t, run("user.attrs.x", "=", 1))
+	assert.Empty(t, run("user.profile.city", "=", "bj"), "nil pointer is allocated")
+	assert.Empty(t, run("user.friends", "=", []interface{}{map[string]interface{}{"city": "sh"}}))
+	assert.Empty(t, run("user.id", "=", 2))
+	assert.Empty(t, run("user", "+", map[string]interface{}{"Age": 21, "extra": map[string]interface{}{"k": "v"}}))
+	assert.Empty(t, run("user.attrs", "+", map[string]interface{}{"y": 2}))
+
+	assert.Equal(t, &testUser{
+		testBase: testBase{ID: 2},
+		Name:     "bar",
+		Level:    "vip",
+		Score:    1,
+		Age:      21,
+		Tags:     []string{"a", "c"},
+		Attrs:    map[string]int{"x": 1, "y": 2},
+		Profile:  &testProfile{City: "bj"},
+		Friends:  []testProfile{{City: "sh"}},
+		Extra:    map[string]string{"k": "v"},
+	}, user)
+
+	assert.Empty(t, run("user", "-", []interface{}{"name", "Age"}))
+	assert.Empty(t, run("user.attrs", "-", []interface{}{"x"}))
+	assert.Equal(t, "", user.Name)
+	assert.Equal(t, 0, user.Age)
+	assert.Equal(t, map[string]int{"y": 2}, user.Attrs)
+
+	errs := run("user.Age", "=", 1.5)
+	require.Len(t, errs, 1)
+	assert.True(t, errs[0].Executor)
+	assert.Contains(t, errs[0].Error(), "Executor[user.Age = 1.5] err:Set field[Age] of core.testUser: Value 1.5 can not be converted to int without losing precision")
+
+	errs = run("user.name", "=", 1)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "Value of type int can not be converted to string")
+
+	errs = run("user.unknown", "=", 1)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "Field[unknown] not found in core.testUser")
+
+	errs = run("user.attrs.z", "=", "z")
+	require.Len(t, errs, 1)
+
+	errs = run("value.name", "=", "bar")
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "use pointer of struct")
+
+	errs = run("user.name", "+", map[string]interface{}{"a": 1})
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "Can not merge into key[user.name] of string")
+}
+
+// lazyData loads value of key on demand
+type lazyData struct {
+	loads []string
+}
+
+func (d *lazyData) AssignmentGet(key string) (interface{}, bool) {
+	d.loads = append(d.loads, key)
+	if key == "missing" {
+		return nil, false
+	}
+	return "v:" + key, true
+}
+
+func TestGetter(t *testing.T) {
+	lazy := &lazyData{}
+	data := map[string]interface{}{
+		"lazy": lazy,
+	}
+
+	value, ok := GetValue(lazy, "a.b")
+	assert.True(t, ok)
+	assert.Equal(t, "v:a.b", value)
+
+	value, ok = GetValue(data, "lazy.c")
+	assert.True(t, ok)
+	assert.Equal(t, "v:c", value, "getter gets the rest of key path")
+
+	_, ok = GetValue(data, "lazy.missing")
+	assert.False(t, ok)
+
+	ctx := WithData(NewContext(), lazy)
+	assert.Equal(t, "v:user.name", GetVariableValue(ctx, GetVariableFactory().Create("data.user.name")))
+
+	ctx = WithContext(context.WithValue(context.Background(), "lazy", lazy))
+	ctx.Set("obj", lazy)
+	assert.Equal(t, "v:level", GetVariableValue(ctx, GetVariableFactory().Create("ctx.obj.level")))
+	assert.Equal(t, "v:age", GetVariableValue(ctx, GetVariableFactory().Create("ctx.lazy.age")))
+}
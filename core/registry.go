@@ -0,0 +1,113 @@
+package core
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// registry is concurrency-safe name => item map shared by factories.
+// Protected names are built-ins, e.g. operation "=", overriding them is logged and unregistering them is ignored.
+type registry struct {
+	kind      string // item kind for messages, e.g. operation
+	mu        sync.RWMutex
+	items     map[string]interface{}
+	protected map[string]bool
+}
+
+func newRegistry(kind string) *registry {
+	return &registry{
+		kind:      kind,
+		items:     make(map[string]interface{}),
+		protected: make(map[string]bool),
+	}
+}
+
+func (r *registry) get(name string) (interface{}, bool) {
+	r.mu.RLock()
+	item, ok := r.items[name]
+	r.mu.RUnlock()
+
+	return item, ok
+}
+
+// register set item with names, overriding protected name is logged
+func (r *registry) register(item interface{}, names []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range names {
+		if r.protected[name] {
+			Logger.Printf("%s[%s] is protected, it's overridden\n", r.kind, name)
+		}
+		r.items[name] = item
+	}
+}
+
+// tryRegister set item with names, returns error without registering anything if any name exists or is protected
+func (r *registry) tryRegister(item interface{}, names []string, exists func(string) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dup := make([]string, 0)
+	for _, name := range names {
+		if _, ok := r.items[name]; ok || r.protected[name] || (exists != nil && exists(name)) {
+			dup = append(dup, name)
+		}
+	}
+
+	if len(dup) > 0 {
+		return errors.Errorf("%s[%s] already registered", r.kind, strings.Join(dup, ","))
+	}
+
+	for _, name := range names {
+		r.items[name] = item
+	}
+
+	return nil
+}
+
+// unregister remove names, protected names are kept
+func (r *registry) unregister(names []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range names {
+		if r.protected[name] {
+			Logger.Printf("%s[%s] is protected, it can not be unregistered\n", r.kind, name)
+			continue
+		}
+		delete(r.items, name)
+	}
+}
+
+func (r *registry) protect(names []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range names {
+		r.protected[name] = true
+	}
+}
+
+// list return sorted names, merged with names of parent
+func (r *registry) list(parent []string) []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.items)+len(parent))
+	for name := range r.items {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+
+	for _, name := range parent {
+		if _, ok := r.get(name); !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFactoryRegistration(t *testing.T) {
+	e := NewEngine(InheritDefault())
+	f := e.OperationFactory()
+	op := &EqualOperation{stringer: stringer("eq")}
+
+	f.Register(op, "eq", "equals")
+	assert.Equal(t, op, f.Get("eq"))
+	assert.Contains(t, f.List(), "eq")
+	assert.Contains(t, f.List(), "in", "list contains operations of default engine")
+
+	assert.Error(t, f.TryRegister(op, "foo", "eq"))
+	assert.Nil(t, f.Get("foo"), "nothing registered if any name exists")
+	assert.Error(t, f.TryRegister(op, "in"), "name exists in default engine")
+	assert.NoError(t, f.TryRegister(op, "foo"))
+
+	f.Unregister("eq", "foo")
+	assert.Nil(t, f.Get("eq"))
+	assert.NotContains(t, f.List(), "eq")
+
+	// override built-ins in engine is allowed
+	f.Register(op, "=")
+	assert.Equal(t, op, f.Get("="))
+
+	// built-ins of default engine are protected
+	assert.Error(t, GetOperationFactory().TryRegister(op, "="))
+	GetOperationFactory().Unregister("in")
+	assert.NotNil(t, GetOperationFactory().Get("in"), "protected name is kept")
+	assert.Error(t, GetVariableFactory().TryRegister(SingletonVariableCreator(NewSimpleVariable("x", Cacheable, &StaticValue{})), "ctx."))
+
+	// overriding protected name is allowed, e.g. custom = at init
+	af := NewEngine().AssignmentFactory()
+	af.Register(&EqualAssignment{}, "=")
+	af.Protect("=")
+	assign := &upperAssignment{}
+	assert.NotPanics(t, func() { af.Register(assign, "=") })
+	assert.Equal(t, assign, af.Get("="))
+	assert.Error(t, af.TryRegister(&EqualAssignment{}, "="))
+
+	// generic names of assignments are not protected
+	GetAssignmentFactory().Unregister("clamp")
+	assert.Nil(t, GetAssignmentFactory().Get("clamp"))
+	GetAssignmentFactory().Register(&ClampAssignment{}, "clamp")
+
+	vf := NewEngine().VariableFactory()
+	vf.Register(VariableCreatorFunc(variableDataCreator), "data.")
+	vf.Protect("data.")
+	assert.Equal(t, []string{"data."}, vf.List())
+	assert.Error(t, vf.TryRegister(VariableCreatorFunc(variableDataCreator), "data."))
+	assert.NotNil(t, vf.Create("data.foo"))
+}
+
+func TestFactoryConcurrency(t *testing.T) {
+	e := NewEngine(InheritDefault())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		name := fmt.Sprintf("v%d", i)
+		go func() {
+			defer wg.Done()
+			e.VariableFactory().Register(SingletonVariableCreator(NewSimpleVariable(name, Cacheable, &StaticValue{Val: 1})), name)
+			e.AssignmentFactory().Register(&EqualAssignment{}, name)
+			e.OperationFactory().Register(&EqualOperation{stringer: stringer(name)}, name)
+			e.VariableFactory().List()
+			e.VariableFactory().Unregister(name)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := e.NewCondition([]interface{}{"succ", "=", true}, LOGIC_ALL)
+			assert.NoError(t, err)
+			e.VariableFactory().Create(name)
+		}()
+	}
+	wg.Wait()
+}
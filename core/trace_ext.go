@@ -0,0 +1,193 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// Mock it for testing
+	_traceNow = time.Now
+)
+
+// traceMessage format log arguments without colors
+func traceMessage(a []interface{}) string {
+	return strings.TrimSpace(fmt.Sprintln(a...))
+}
+
+// TraceEvent is event emitted by JSON trace, one json object per line.
+//   event    : enter, leave, log
+//   depth    : nesting depth of event, enter event has the depth of its parent
+//   duration : nanoseconds since matching enter event, only for leave event
+type TraceEvent struct {
+	Event    string        `json:"event"`
+	Name     string        `json:"name,omitempty"`
+	Message  string        `json:"message,omitempty"`
+	Depth    int           `json:"depth"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+type jsonTrace struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	starts  []time.Time
+}
+
+// NewJSONTrace return Trace that writes structured json events to w, one event per line.
+func NewJSONTrace(w io.Writer) Trace {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	return &jsonTrace{
+		encoder: encoder,
+	}
+}
+
+func (t *jsonTrace) Enter(name string) Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := _traceNow()
+	t.write(&TraceEvent{
+		Event: "enter",
+		Name:  name,
+		Depth: len(t.starts),
+		Time:  now,
+	})
+	t.starts = append(t.starts, now)
+
+	return t
+}
+
+func (t *jsonTrace) Leave(name string) Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := _traceNow()
+	event := &TraceEvent{
+		Event: "leave",
+		Name:  name,
+		Time:  now,
+	}
+	if n := len(t.starts); n > 0 {
+		event.Duration = now.Sub(t.starts[n-1])
+		t.starts = t.starts[:n-1]
+	}
+	event.Depth = len(t.starts)
+	t.write(event)
+
+	return t
+}
+
+func (t *jsonTrace) Log(a ...interface{}) Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.write(&TraceEvent{
+		Event:   "log",
+		Message: traceMessage(a),
+		Depth:   len(t.starts),
+		Time:    _traceNow(),
+	})
+
+	return t
+}
+
+func (t *jsonTrace) write(event *TraceEvent) {
+	if err := t.encoder.Encode(event); err != nil {
+		Logger.Printf("Write trace event err:%v\n", err)
+	}
+}
+
+// Span is a traced step, created by Trace.Enter and finished by Trace.Leave
+type Span struct {
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Logs     []*SpanLog    `json:"logs,omitempty"`
+	Children []*Span       `json:"children,omitempty"`
+}
+
+// SpanLog is log in span
+type SpanLog struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// SpanTrace is Trace that collects an in-memory span tree.
+// Use one SpanTrace for each run, then attach Root() to request logs or debug response.
+type SpanTrace struct {
+	mu    sync.Mutex
+	root  *Span
+	stack []*Span
+}
+
+// NewSpanTrace return SpanTrace, the root span starts now
+func NewSpanTrace() *SpanTrace {
+	root := &Span{
+		Start: _traceNow(),
+	}
+
+	return &SpanTrace{
+		root:  root,
+		stack: []*Span{root},
+	}
+}
+
+func (t *SpanTrace) Enter(name string) Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &Span{
+		Name:  name,
+		Start: _traceNow(),
+	}
+	parent := t.stack[len(t.stack)-1]
+	parent.Children = append(parent.Children, span)
+	t.stack = append(t.stack, span)
+
+	return t
+}
+
+func (t *SpanTrace) Leave(name string) Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := _traceNow()
+
+	// never leave root span
+	if n := len(t.stack); n > 1 {
+		span := t.stack[n-1]
+		span.Duration = now.Sub(span.Start)
+		t.stack = t.stack[:n-1]
+	}
+	t.root.Duration = now.Sub(t.root.Start)
+
+	return t
+}
+
+func (t *SpanTrace) Log(a ...interface{}) Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := t.stack[len(t.stack)-1]
+	span.Logs = append(span.Logs, &SpanLog{
+		Time:    _traceNow(),
+		Message: traceMessage(a),
+	})
+
+	return t
+}
+
+// Root return root span, top level spans are its children
+func (t *SpanTrace) Root() *Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.root
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mockTraceNow() func() {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	original := _traceNow
+	_traceNow = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+
+	return func() {
+		_traceNow = original
+	}
+}
+
+func TestJSONTrace(t *testing.T) {
+	defer mockTraceNow()()
+
+	var b bytes.Buffer
+	trace := NewJSONTrace(&b)
+	trace.Enter("FILTER").Log("a", true).Enter("COND").Leave("COND").Leave("END")
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Equal(t, 5, len(lines))
+
+	events := make([]*TraceEvent, len(lines))
+	for i, line := range lines {
+		events[i] = &TraceEvent{}
+		require.NoError(t, json.Unmarshal([]byte(line), events[i]))
+	}
+
+	assert.Equal(t, "enter", events[0].Event)
+	assert.Equal(t, 0, events[0].Depth)
+	assert.Equal(t, "log", events[1].Event)
+	assert.Equal(t, "a true", events[1].Message)
+	assert.Equal(t, 1, events[1].Depth)
+	assert.Equal(t, 1, events[2].Depth)
+	assert.Equal(t, "leave", events[3].Event)
+	assert.Equal(t, 1, events[3].Depth)
+	assert.Equal(t, time.Millisecond, events[3].Duration)
+	assert.Equal(t, 0, events[4].Depth)
+	assert.Equal(t, 4*time.Millisecond, events[4].Duration)
+}
+
+func TestSpanTrace(t *testing.T) {
+	defer mockTraceNow()()
+
+	trace := NewSpanTrace()
+	trace.Log("start").Enter("FILTER").Log("a", false).Enter("COND").Leave("COND").Leave("END")
+	// unbalanced leave is ignored
+	trace.Leave("END")
+
+	root := trace.Root()
+	require.Equal(t, 1, len(root.Logs))
+	assert.Equal(t, "start", root.Logs[0].Message)
+	require.Equal(t, 1, len(root.Children))
+
+	span := root.Children[0]
+	assert.Equal(t, "FILTER", span.Name)
+	assert.Equal(t, 4*time.Millisecond, span.Duration)
+	assert.Equal(t, "a false", span.Logs[0].Message)
+	require.Equal(t, 1, len(span.Children))
+	assert.Equal(t, "COND", span.Children[0].Name)
+	assert.Equal(t, time.Millisecond, span.Children[0].Duration)
+	assert.Equal(t, 7*time.Millisecond, root.Duration)
+
+	_, err := json.Marshal(root)
+	assert.NoError(t, err)
+}
@@ -1,11 +1,13 @@
 package core
 
 import (
+	"bytes"
 	"encoding/json"
 	"reflect"
 	"strings"
 
 	"github.com/mohae/deepcopy"
+	"github.com/pkg/errors"
 	"github.com/spaolacci/murmur3"
 	"github.com/techxmind/go-utils/itype"
 )
@@ -65,6 +67,66 @@ func jstr(v interface{}) string {
 	return strings.TrimSpace(s.String())
 }
 
+// MarshalDefinition encode definition data like json.Marshal, but "=>" and other html characters are not escaped
+func MarshalDefinition(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
+
+// Definition wrap condition, executor or filter as element of definition data.
+// They output definitions by implementing json.Marshaler optionally, marshaling fails if v doesn't,
+// instead of encoding custom implementation as struct.
+func Definition(v interface{}) json.Marshaler {
+	return definition{v}
+}
+
+type definition struct {
+	v interface{}
+}
+
+func (d definition) MarshalJSON() ([]byte, error) {
+	m, ok := d.v.(json.Marshaler)
+	if !ok {
+		return nil, errors.Errorf("%T can not output definition, it doesn't implement json.Marshaler", d.v)
+	}
+
+	return m.MarshalJSON()
+}
+
+// Normalize convert map[interface{}]interface{} (usually from yaml decoder) in value to map[string]interface{} recursively,
+// so the value can be accessed like data from json universal unmarshal.
+// Maps and lists are copied, the others are returned as they are.
+func Normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[itype.String(key)] = Normalize(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[key] = Normalize(val)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, val := range v {
+			list[i] = Normalize(val)
+		}
+		return list
+	}
+
+	return value
+}
+
 func IsArray(v interface{}) bool {
 	return itype.GetType(v) == itype.ARRAY
 }
@@ -62,3 +62,24 @@ func TestIsArray(t *testing.T) {
 		assert.Equal(t, test.check, IsArray(test.in), "case %d : %v", i, test.in)
 	}
 }
+
+func TestNormalize(t *testing.T) {
+	in := []interface{}{
+		map[interface{}]interface{}{
+			"a": map[interface{}]interface{}{1: "b"},
+			"c": []interface{}{map[interface{}]interface{}{"d": true}},
+		},
+		"e",
+	}
+
+	expected := []interface{}{
+		map[string]interface{}{
+			"a": map[string]interface{}{"1": "b"},
+			"c": []interface{}{map[string]interface{}{"d": true}},
+		},
+		"e",
+	}
+
+	assert.Equal(t, expected, Normalize(in))
+	assert.Equal(t, 1, Normalize(1))
+}
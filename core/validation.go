@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorCode of definition validation error
+type ErrorCode string
+
+const (
+	ERR_EMPTY              ErrorCode = "EMPTY"
+	ERR_NOT_ARRAY          ErrorCode = "NOT_ARRAY"
+	ERR_NOT_STRING         ErrorCode = "NOT_STRING"
+	ERR_ELEMENT_COUNT      ErrorCode = "ELEMENT_COUNT"
+	ERR_UNKNOWN_VARIABLE   ErrorCode = "UNKNOWN_VARIABLE"
+	ERR_UNKNOWN_OPERATION  ErrorCode = "UNKNOWN_OPERATION"
+	ERR_UNKNOWN_ASSIGNMENT ErrorCode = "UNKNOWN_ASSIGNMENT"
+	ERR_INVALID_VALUE      ErrorCode = "INVALID_VALUE"
+	ERR_UNKNOWN_CONDITION  ErrorCode = "UNKNOWN_CONDITION"
+	ERR_CONDITION_CYCLE    ErrorCode = "CONDITION_CYCLE"
+)
+
+// ValidationError describe a problem of filter definition.
+//
+//	Path    : location of the offending element in definition, e.g. [2][1][2]
+//	Element : the offending element
+//	Code    : error code
+//	Message : error detail
+type ValidationError struct {
+	Path    string
+	Element interface{}
+	Code    ErrorCode
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "$"
+	}
+
+	return fmt.Sprintf("%s %s: %s", path, e.Code, e.Message)
+}
+
+// ValidationErrors contains all problems of filter definition
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Prefix return errors with path prefixed, for validating sub definition
+func (errs ValidationErrors) Prefix(path string) ValidationErrors {
+	if len(errs) == 0 {
+		return errs
+	}
+
+	ret := make(ValidationErrors, len(errs))
+	for i, err := range errs {
+		e := *err
+		e.Path = path + e.Path
+		ret[i] = &e
+	}
+
+	return ret
+}
+
+// Add record a validation error
+func (errs *ValidationErrors) Add(path string, element interface{}, code ErrorCode, format string, a ...interface{}) {
+	*errs = append(*errs, &ValidationError{
+		Path:    path,
+		Element: element,
+		Code:    code,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// ValidateCondition check condition definition, return all problems found
+func ValidateCondition(item []interface{}, groupLogic GROUP_LOGIC) ValidationErrors {
+	return _defaultEngine.ValidateCondition(item, groupLogic)
+}
+
+// ValidateExecutor check executor definition, return all problems found
+func ValidateExecutor(item []interface{}) ValidationErrors {
+	return _defaultEngine.ValidateExecutor(item)
+}
+
+func indexPath(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCondition(t *testing.T) {
+	errs := ValidateCondition([]interface{}{
+		[]interface{}{"ctx.a", "=", 1},
+		[]interface{}{"unknown-var", "unknown-op", 1},
+		[]interface{}{"any?", "=>", []interface{}{
+			[]interface{}{"ctx.b", "between", "1,2,3"},
+			"foo",
+			[]interface{}{"ctx.c", "="},
+		}},
+	}, LOGIC_ALL)
+
+	type check struct {
+		path string
+		code ErrorCode
+	}
+	expected := []check{
+		{"[1][0]", ERR_UNKNOWN_VARIABLE},
+		{"[1][1]", ERR_UNKNOWN_OPERATION},
+		{"[2][2][0][2]", ERR_INVALID_VALUE},
+		{"[2][2][1]", ERR_NOT_ARRAY},
+		{"[2][2][2]", ERR_ELEMENT_COUNT},
+	}
+
+	if assert.Equal(t, len(expected), len(errs), errs.Error()) {
+		for i, c := range expected {
+			assert.Equal(t, c.path, errs[i].Path, "case %d", i)
+			assert.Equal(t, c.code, errs[i].Code, "case %d", i)
+		}
+	}
+	assert.Equal(t, "foo", errs[3].Element)
+
+	assert.Empty(t, ValidateCondition([]interface{}{"ctx.a", "=", 1}, LOGIC_ALL))
+}
+
+func TestValidateExecutor(t *testing.T) {
+	errs := ValidateExecutor([]interface{}{
+		[]interface{}{"a", "=", 1},
+		[]interface{}{1, "?", 1},
+		[]interface{}{"b", "=>", []interface{}{
+			[]interface{}{"c", "+", "d"},
+		}},
+	})
+
+	if assert.Equal(t, 3, len(errs), errs.Error()) {
+		assert.Equal(t, "[1][0]", errs[0].Path)
+		assert.Equal(t, ERR_NOT_STRING, errs[0].Code)
+		assert.Equal(t, "[1][1]", errs[1].Path)
+		assert.Equal(t, ERR_UNKNOWN_ASSIGNMENT, errs[1].Code)
+		assert.Equal(t, "[2][2][0][2]", errs[2].Path)
+		assert.Equal(t, ERR_INVALID_VALUE, errs[2].Code)
+	}
+
+	_, err := NewExecutor([]interface{}{"b", "=>", []interface{}{"c", "+", "d"}})
+	assert.Error(t, err)
+}
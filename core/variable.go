@@ -2,15 +2,14 @@ package core
 
 import (
 	"strings"
+	"time"
 )
 
 var (
 	Cacheable   = true
 	Uncacheable = false
 
-	_variableFactory = &stdVariableFactory{
-		creators: make(map[string]VariableCreator),
-	}
+	_variableFactory = newStdVariableFactory()
 )
 
 type Variable interface {
@@ -33,9 +32,19 @@ func (f VariableCreatorFunc) Create(name string) Variable {
 	return f(name)
 }
 
+// VariableFactory is concurrency-safe registry of variable creators
 type VariableFactory interface {
 	VariableCreator
+	// Register variable creator with names, overriding protected name is logged
 	Register(VariableCreator, ...string)
+	// TryRegister register variable creator only if none of the names is registered
+	TryRegister(VariableCreator, ...string) error
+	// Unregister variable names, protected names are kept
+	Unregister(...string)
+	// Protect names of built-ins, TryRegister and Unregister deny them, Register logs overriding
+	Protect(...string)
+	// List return registered variable names, prefix name is ends with '.', e.g. data.
+	List() []string
 }
 
 // GetVariableFactory return VariableFactory
@@ -45,51 +54,136 @@ func GetVariableFactory() VariableFactory {
 
 // stdVariableFactory default VariableFactory
 type stdVariableFactory struct {
-	creators map[string]VariableCreator
+	registry *registry
+
+	// search variable in parent if not found
+	parent *stdVariableFactory
+}
+
+func newStdVariableFactory() *stdVariableFactory {
+	return &stdVariableFactory{
+		registry: newRegistry("variable"),
+	}
 }
 
 func (self *stdVariableFactory) Register(creator VariableCreator, names ...string) {
-	for _, name := range names {
-		self.creators[name] = creator
+	self.registry.register(creator, names)
+}
+
+func (self *stdVariableFactory) TryRegister(creator VariableCreator, names ...string) error {
+	return self.registry.tryRegister(creator, names, func(name string) bool {
+		return self.parent != nil && self.parent.has(name)
+	})
+}
+
+func (self *stdVariableFactory) Unregister(names ...string) {
+	self.registry.unregister(names)
+}
+
+func (self *stdVariableFactory) Protect(names ...string) {
+	self.registry.protect(names)
+}
+
+func (self *stdVariableFactory) List() []string {
+	var parent []string
+	if self.parent != nil {
+		parent = self.parent.List()
+	}
+
+	return self.registry.list(parent)
+}
+
+// has check if name is registered
+func (self *stdVariableFactory) has(name string) bool {
+	if _, ok := self.registry.get(name); ok {
+		return true
 	}
+
+	return self.parent != nil && self.parent.has(name)
 }
 
 func (f *stdVariableFactory) Create(name string) Variable {
-	if creator, ok := f.creators[name]; ok {
+	if creator, ok := f.registry.get(name); ok {
 
-		return creator.Create(name)
+		return creator.(VariableCreator).Create(name)
 	} else {
 		segments := strings.Split(name, ".")
 		if len(segments) > 1 {
-			if creator, ok := f.creators[segments[0]+"."]; ok {
-				return creator.Create(name)
+			if creator, ok := f.registry.get(segments[0] + "."); ok {
+				return creator.(VariableCreator).Create(name)
 			}
 		}
 	}
 
+	if f.parent != nil {
+		return f.parent.Create(name)
+	}
+
 	return nil
 }
 
 // GetVariableValue get value of variable, also handlers variable cacheing, hooking logic
-//
+// Error reported by ValuerE is logged, and value is nil.
 func GetVariableValue(ctx *Context, v Variable) interface{} {
+	value, err := GetVariableValueE(ctx, v)
+	if err != nil {
+		Logger.Printf("Get variable[%s] value err:%v\n", v.Name(), err)
+	}
+
+	return value
+}
+
+// GetVariableValueE is GetVariableValue that returns error reported by ValuerE, value reported with error is not cached.
+func GetVariableValueE(ctx *Context, v Variable) (interface{}, error) {
 	if v == nil {
-		return ""
+		return "", nil
 	}
 
+	if observer := ctx.Observer(); observer != nil {
+		// value is resolved and observed already
+		if _, ok := v.(*resolvedVariable); !ok {
+			start := time.Now()
+			value, cached, err := getVariableValue(ctx, v)
+			observer.ObserveVariable(v.Name(), cached, time.Since(start))
+			return value, err
+		}
+	}
+
+	value, _, err := getVariableValue(ctx, v)
+
+	return value, err
+}
+
+func getVariableValue(ctx *Context, v Variable) (value interface{}, cached bool, err error) {
 	if v.Cacheable() {
 		if value, ok := ctx.Cache().Load(v.Name()); ok {
-			return value
+			// variable is being prefetched
+			if call, ok := value.(*prefetchCall); ok {
+				value, err := call.wait(ctx, v.Name())
+				return value, true, err
+			}
+			return value, true, nil
 		}
 	}
 
-	value := v.Value(ctx)
+	if value, err = resolveVariable(ctx, v); err != nil {
+		return value, false, err
+	}
 
 	if v.Cacheable() {
 		ctx.Cache().Store(v.Name(), value)
 	}
 
-	return value
+	return value, false, nil
+}
+
+// resolveVariable get value of variable without cache
+func resolveVariable(ctx *Context, v Variable) (interface{}, error) {
+	if ve, ok := v.(ValuerE); ok {
+		return ve.ValueE(ctx)
+	}
+
+	return v.Value(ctx), nil
 }
 
 // ValueFunc implements Valuer interface
@@ -135,6 +229,15 @@ func (v *SimpleVariable) Value(ctx *Context) interface{} {
 	return v.value.Value(ctx)
 }
 
+// ValueE implements ValuerE, reports error if the valuer implements ValuerE
+func (v *SimpleVariable) ValueE(ctx *Context) (interface{}, error) {
+	if ve, ok := v.value.(ValuerE); ok {
+		return ve.ValueE(ctx)
+	}
+
+	return v.value.Value(ctx), nil
+}
+
 func SingletonVariableCreator(instance Variable) VariableCreatorFunc {
 	return func(name string) Variable {
 		return instance
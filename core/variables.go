@@ -4,8 +4,6 @@ import (
 	"math/rand"
 	"strings"
 	"time"
-
-	"github.com/techxmind/go-utils/object"
 )
 
 // register core varaiables
@@ -41,7 +39,7 @@ func init() {
 	_variableFactory.Register(
 		SingletonVariableCreator(
 			NewSimpleVariable("rand", Uncacheable, ValueFunc(func(ctx *Context) interface{} {
-				return rand.Intn(100) + 1
+				return int(ctx.Rand().Int63n(100)) + 1
 			})),
 		),
 		"rand",
@@ -61,6 +59,9 @@ func init() {
 
 	// variable: ctx.xx
 	_variableFactory.Register(VariableCreatorFunc(variableCtxCreator), "ctx.")
+
+	// built-in variables can not be overridden in default engine
+	_variableFactory.Protect(append(names, "succ", "rand", "data.", "ctx.")...)
 }
 
 var (
@@ -120,7 +121,7 @@ type variableData struct {
 func (self *variableData) Cacheable() bool { return false }
 func (self *variableData) Name() string    { return self.name }
 func (self *variableData) Value(ctx *Context) interface{} {
-	if v, ok := object.GetValue(ctx.Data(), self.key); ok {
+	if v, ok := GetValue(ctx.Data(), self.key); ok {
 		return v
 	}
 
@@ -159,12 +160,12 @@ func (self *variableCtx) Name() string {
 func (self *variableCtx) Value(ctx *Context) interface{} {
 
 	// First priority: data["ctx"][key...]
-	if value, ok := object.GetValue(ctx.Data(), "ctx."+self.key); ok {
+	if value, ok := GetValue(ctx.Data(), "ctx."+self.key); ok {
 		return value
 	}
 
 	// Secondary priority: from Context.Set(topKey, value)
-	if value, ok := object.GetValue(ctx.GetAll(), self.key); ok {
+	if value, ok := GetValue(ctx.GetAll(), self.key); ok {
 		return value
 	}
 
@@ -176,7 +177,7 @@ func (self *variableCtx) Value(ctx *Context) interface{} {
 			return v
 		}
 
-		v, _ := object.GetValue(v, strings.Join(paths[1:], "."))
+		v, _ := GetValue(v, strings.Join(paths[1:], "."))
 
 		return v
 	}
@@ -0,0 +1,17 @@
+package filter
+
+import (
+	"context"
+
+	"github.com/techxmind/filter/core"
+)
+
+// RunCopyOnWrite run filter without changing data, return the new root changed by executors.
+// Only containers on the paths that executors change are copied, other parts are shared with data,
+// so data can be shared base data of concurrent runs. Root is data itself if nothing is changed.
+func RunCopyOnWrite(ctx context.Context, f Filter, data interface{}) (succ bool, root interface{}) {
+	cow := core.NewCopyOnWrite(data)
+	succ = f.Run(core.WithContext(ctx, core.WithCopyOnWrite(cow)), data)
+
+	return succ, cow.Root()
+}
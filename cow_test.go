@@ -0,0 +1,40 @@
+package filter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techxmind/filter/core"
+)
+
+func TestRunCopyOnWrite(t *testing.T) {
+	f, err := New(arr(
+		arr("f1", arr("succ", "=", true), arr("a.b", "=", 1)),
+		arr("f2", arr("data.a.b", "=", 1), arr("c", "=", 1)),
+		arr("f3", arr("succ", "=", false), arr("d", "=", 1)),
+	))
+	require.NoError(t, err)
+
+	base := map[string]interface{}{
+		"a": map[string]interface{}{"b": 0},
+		"x": map[string]interface{}{"y": 1},
+	}
+	origin := core.Clone(base)
+
+	succ, root := RunCopyOnWrite(context.Background(), f, base)
+	assert.True(t, succ)
+	assert.Equal(t, origin, base, "base data is not changed")
+	assert.Equal(t, map[string]interface{}{
+		"a": map[string]interface{}{"b": 1},
+		"c": 1,
+		"x": map[string]interface{}{"y": 1},
+	}, root, "f2 evaluates data changed by f1")
+
+	// nothing changed
+	succ, root = RunCopyOnWrite(context.Background(), f.(*FilterGroup).filters[2], base)
+	assert.False(t, succ)
+	assert.Equal(t, base, root)
+}
@@ -0,0 +1,47 @@
+package filter
+
+import (
+	"context"
+
+	"github.com/techxmind/filter/core"
+)
+
+const dryRunCtxKey ctxKey = "dryrun"
+
+// dryRun records changes of executors
+type dryRun struct {
+	ops []*core.PatchOperation
+}
+
+func getDryRun(ctx context.Context) *dryRun {
+	if d := ctx.Value(dryRunCtxKey); d != nil {
+		return d.(*dryRun)
+	}
+
+	return nil
+}
+
+// DryRun run filter on a copy of data, the input data is not touched.
+// Return changes that executors would make as RFC 6902 JSON Patch operations in the order of filters,
+// operations of the same filter are in the order of core.Diff. Filter of each operation is the name of filter that makes the change.
+func DryRun(ctx context.Context, f Filter, data interface{}) (bool, []*core.PatchOperation) {
+	d := &dryRun{
+		ops: make([]*core.PatchOperation, 0),
+	}
+
+	succ := f.Run(context.WithValue(ctx, dryRunCtxKey, d), core.Clone(data))
+
+	return succ, d.ops
+}
+
+// execute run executor of filter, record changes in dry run mode
+func (d *dryRun) execute(ctx *core.Context, f *singleFilter, data interface{}) {
+	before := core.Clone(data)
+
+	f.executor.Execute(ctx, data)
+
+	for _, op := range core.Diff(before, data) {
+		op.Filter = f.name
+		d.ops = append(d.ops, op)
+	}
+}
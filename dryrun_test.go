@@ -0,0 +1,49 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+	f, err := New(arr(
+		arr("f1", arr("succ", "=", true), arr(
+			arr("a", "=", 1),
+			arr("b", "+", map[string]interface{}{"c": 1}),
+		)),
+		arr("f2", arr("succ", "=", false), arr("x", "=", 1)),
+		arr("f3", arr("data.a", "=", 1), arr(
+			arr("a", "=", 2),
+			arr("$", "-", arr("d")),
+		)),
+	), Name("g"))
+	require.NoError(t, err)
+
+	data := map[string]interface{}{
+		"b": map[string]interface{}{"e": 1},
+		"d": "d",
+	}
+	succ, ops := DryRun(context.Background(), f, data)
+	assert.True(t, succ)
+	assert.Equal(t, map[string]interface{}{
+		"b": map[string]interface{}{"e": 1},
+		"d": "d",
+	}, data, "input data is not touched")
+
+	b, err := json.Marshal(ops)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op":"add","path":"/b/c","value":1,"filter":"g.f1"},
+		{"op":"add","path":"/a","value":1,"filter":"g.f1"},
+		{"op":"replace","path":"/a","value":2,"filter":"g.f3"},
+		{"op":"remove","path":"/d","filter":"g.f3"}
+	]`, string(b))
+
+	succ, ops = DryRun(context.Background(), f.(*FilterGroup).filters[1], data)
+	assert.False(t, succ)
+	assert.Empty(t, ops)
+}
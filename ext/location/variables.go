@@ -5,6 +5,8 @@
 package location
 
 import (
+	"github.com/pkg/errors"
+
 	"github.com/techxmind/filter/core"
 	"github.com/techxmind/ip2location"
 )
@@ -46,24 +48,35 @@ type VariableLocation struct {
 func (v *VariableLocation) Cacheable() bool { return true }
 func (v *VariableLocation) Name() string    { return v.name }
 func (v *VariableLocation) Value(ctx *core.Context) interface{} {
+	value, _ := v.ValueE(ctx)
+
+	return value
+}
+
+// ValueE implements core.ValuerE, reports error if ip variable or ip lookup fails
+func (v *VariableLocation) ValueE(ctx *core.Context) (interface{}, error) {
 	ipVar := _getIpVar()
 	if ipVar == nil {
-		return nil
+		return nil, errors.New("Variable[ip] is not registered")
+	}
+	ipValue, err := core.GetVariableValueE(ctx, ipVar)
+	if err != nil {
+		return nil, errors.Wrap(err, "Get ip")
 	}
-	ip, ok := core.GetVariableValue(ctx, ipVar).(string)
+	ip, ok := ipValue.(string)
 	if !ok {
-		return nil
+		return nil, nil
 	}
 	loc, err := _getLocation(ip)
 	if err != nil {
-		return nil
+		return nil, errors.Wrapf(err, "Get location of ip[%s]", ip)
 	}
 
 	if v.name == "country" {
-		return loc.Country
+		return loc.Country, nil
 	} else if v.name == "province" {
-		return loc.Province
+		return loc.Province, nil
 	} else {
-		return loc.City
+		return loc.City, nil
 	}
 }
@@ -3,6 +3,8 @@ package location
 import (
 	"testing"
 
+	"github.com/pkg/errors"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -42,3 +44,25 @@ func TestLocation(t *testing.T) {
 	require.NotNil(t, v)
 	assert.Equal(t, "南京市", core.GetVariableValue(ctx, v))
 }
+
+func TestLocationError(t *testing.T) {
+	originalGetLocation := _getLocation
+	_getLocation = func(_ string) (*ip2location.Location, error) {
+		return nil, errors.New("not found")
+	}
+	originalGetIpVar := _getIpVar
+	_getIpVar = func() core.Variable {
+		return core.NewSimpleVariable("ip", core.Cacheable, &core.StaticValue{Val: "8.8.8.8"})
+	}
+	defer func() {
+		_getLocation = originalGetLocation
+		_getIpVar = originalGetIpVar
+	}()
+
+	v := core.GetVariableFactory().Create("city")
+	require.NotNil(t, v)
+	value, err := core.GetVariableValueE(core.NewContext(), v)
+	assert.Nil(t, value)
+	assert.EqualError(t, err, "Get location of ip[8.8.8.8]: not found")
+	assert.Nil(t, core.GetVariableValue(core.NewContext(), v))
+}
@@ -6,6 +6,8 @@ import (
 	"regexp"
 	"strconv"
 
+	"github.com/pkg/errors"
+
 	"github.com/techxmind/filter/core"
 	"github.com/techxmind/go-utils/itype"
 	"github.com/techxmind/go-utils/object"
@@ -110,10 +112,20 @@ type VariableQueryStr struct {
 func (self *VariableQueryStr) Cacheable() bool { return true }
 func (self *VariableQueryStr) Name() string    { return self.name }
 func (self *VariableQueryStr) Value(ctx *core.Context) interface{} {
+	value, err := self.ValueE(ctx)
+	if err != nil {
+		core.Logger.Printf("%v\n", err)
+	}
+
+	return value
+}
+
+// ValueE implements core.ValuerE, reports error if json value of query is invalid, value is "" with error as Value returns
+func (self *VariableQueryStr) ValueE(ctx *core.Context) (interface{}, error) {
 	value := self.queryValueGetter(ctx, self.paramName)
 
 	if value == "" || (!self.listMode && !self.jsonMode) {
-		return value
+		return value, nil
 	}
 
 	var (
@@ -127,30 +139,29 @@ func (self *VariableQueryStr) Value(ctx *core.Context) interface{} {
 			data = cacheData
 		} else {
 			if err := json.Unmarshal([]byte(value), &data); err != nil {
-				core.Logger.Printf("json.Unmarshal url query variable[%s] err. value=%s err=%v\n", self.paramName, value, err)
-				return ""
+				return "", errors.Wrapf(err, "json.Unmarshal url query variable[%s] value=%s", self.paramName, value)
 			}
 		}
 		if data == nil {
 			core.Logger.Printf("Query variable[%s] json.Unmarshal get nil. value=%s", self.paramName, value)
-			return nil
+			return nil, nil
 		}
 		if v, ok := object.GetValue(data, self.jsonKey); ok {
 			ivalue = v
 		} else {
-			return nil
+			return nil, nil
 		}
 	}
 
 	if self.listMode {
 		arr := core.ToArray(ivalue)
 		if self.listIndex < 0 || self.listIndex >= len(arr) {
-			return nil
+			return nil, nil
 		}
-		return arr[self.listIndex]
+		return arr[self.listIndex], nil
 	}
 
-	return ivalue
+	return ivalue, nil
 }
 
 var _getRegexp = regexp.MustCompile("^(?:get|query).(.+?)(?:\\{([^\\}]+)\\})?(?:\\[(\\d+)\\])?$")
@@ -55,3 +55,15 @@ func TestAll(t *testing.T) {
 		assert.EqualValues(t, c.expected, core.GetVariableValue(ctx, v), "case %d: %s = %v", i, c.input, c.expected)
 	}
 }
+
+func TestQueryJSONError(t *testing.T) {
+	ctx := core.WithContext(context.WithValue(context.Background(), REQUEST_URL, `http://www.techxmind.com/?c={bad`))
+	v := core.GetVariableFactory().Create("get.c{d}")
+	require.NotNil(t, v)
+
+	value, err := core.GetVariableValueE(ctx, v)
+	assert.Error(t, err)
+	assert.Equal(t, "", value)
+	assert.Equal(t, "", core.GetVariableValue(ctx, v))
+	assert.Equal(t, "", v.Value(ctx))
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 
 	"github.com/techxmind/filter/core"
 )
@@ -25,11 +27,94 @@ type singleFilter struct {
 	name      string
 	condition core.Condition
 	executor  core.Executor
+
+	// name in definition, without name prefix
+	definitionName string
+
+	// variables referenced by conditions
+	variables []core.Variable
+
+	prefetch        bool
+	prefetchTimeout time.Duration
+
+	// rank options in metadata of definition
+	weight   int64
+	priority int64
 }
 
 func (f *singleFilter) Name() string { return f.name }
-func (f *singleFilter) Run(pctx context.Context, data interface{}) bool {
-	ctx := core.WithData(pctx, data)
+
+// MarshalJSON output the definition data that New accepts: ["$filter-name", [$condition]..., [$executor]]
+func (f *singleFilter) MarshalJSON() ([]byte, error) {
+	return core.MarshalDefinition(f.definition())
+}
+
+func (f *singleFilter) definition() []interface{} {
+	items := []interface{}{f.definitionName}
+
+	if group, ok := f.condition.(*core.ConditionGroup); ok && group.Logic() == core.LOGIC_ALL {
+		for _, condition := range group.Conditions() {
+			items = append(items, core.Definition(condition))
+		}
+	} else {
+		items = append(items, core.Definition(f.condition))
+	}
+
+	if f.executor != nil {
+		// executor is wrapped by group in buildFilter
+		if group, ok := f.executor.(*core.ExecutorGroup); ok && len(group.Executors()) == 1 {
+			items = append(items, core.Definition(group.Executors()[0]))
+		} else {
+			items = append(items, core.Definition(f.executor))
+		}
+	} else {
+		items = append(items, nil)
+	}
+
+	return items
+}
+
+func (f *singleFilter) Run(pctx context.Context, data interface{}) (succ bool) {
+	var (
+		ctx    *core.Context
+		result *Result
+	)
+
+	// evaluate the latest root changed by previous filters
+	if cow := core.WithContext(pctx).CopyOnWrite(); cow != nil {
+		data = cow.Root()
+	}
+
+	if recorder := getResultRecorder(pctx); recorder != nil {
+		result = recorder.enter(f.name)
+		crecorder := &core.Recorder{}
+		ctx = core.WithData(pctx, data, core.WithRecorder(crecorder))
+		errCount := ctx.ErrorCollector().Len()
+		defer func() {
+			result.Succ = succ
+			result.Condition = crecorder.Condition
+			result.Executors = crecorder.Executors
+			result.Errors = ctx.ErrorCollector().Since(errCount)
+			if succ {
+				recorder.fire(f.name)
+			}
+			recorder.leave()
+		}()
+	} else {
+		ctx = core.WithData(pctx, data)
+	}
+
+	if observer := ctx.Observer(); observer != nil {
+		start := time.Now()
+		defer func() {
+			observer.ObserveFilter(f.name, succ, time.Since(start))
+		}()
+	}
+
+	if f.prefetch {
+		core.Prefetch(ctx, f.variables, f.prefetchTimeout)
+	}
+
 	trace := ctx.Trace()
 
 	if trace != nil {
@@ -42,16 +127,32 @@ func (f *singleFilter) Run(pctx context.Context, data interface{}) bool {
 		trace.Leave("COND").Log("RET", ok)
 	}
 
+	// executors are not applied if the run is cut short
+	if ctx.Interrupted() {
+		interrupt(ctx, result)
+		return false
+	}
+
 	if !ok {
 		return false
 	}
 
+	// executors are not run in match mode
+	if m := getMatcher(pctx); m != nil {
+		m.names = append(m.names, f.name)
+		return true
+	}
+
 	if f.executor != nil {
 		if trace != nil {
 			trace.Enter("EXEC")
 		}
 
-		f.executor.Execute(ctx, data)
+		if d := getDryRun(pctx); d != nil {
+			d.execute(ctx, f, data)
+		} else {
+			f.executor.Execute(ctx, data)
+		}
 
 		if trace != nil {
 			trace.Leave("EXEC")
@@ -66,24 +167,69 @@ type FilterGroup struct {
 	filters []Filter
 	// when shortMode = true, Run method will return immediately when find the first filter that return true.
 	shortMode bool
+	// when topK > 0, Run method will return when topK filters return true.
+	topK int
 
 	// when enableRank = true, run filters with rank order and usually is running in shortMode.
 	enableRank   bool
 	ranks        []*rank
 	rankBoundary []*rankBoundary
+	// rank is sticky to value of variable, salt is group name by default
+	stickyVariable core.Variable
+	stickyBy       string
+	stickySalt     string
+	// sticky variable is inherited from parent group, it's not in definition of group
+	stickyInherited bool
+
+	// variables referenced by filters
+	variables []core.Variable
+
+	prefetch        bool
+	prefetchTimeout time.Duration
+
+	// name in definition without name prefix, it's name of group by default
+	definitionName string
+
+	// rank options in metadata of definition
+	weight   int64
+	priority int64
 }
 
 func NewFilterGroup(options ...Option) *FilterGroup {
 	opts := getFilterOpts(options)
 
-	return &FilterGroup{
+	group := &FilterGroup{
 		name:         opts.name,
 		filters:      make([]Filter, 0),
 		shortMode:    opts.shortMode,
+		topK:         opts.topK,
 		enableRank:   opts.enableRank,
 		ranks:        make([]*rank, 0),
 		rankBoundary: make([]*rankBoundary, 0),
+
+		variables:       make([]core.Variable, 0),
+		prefetch:        opts.prefetch,
+		prefetchTimeout: opts.prefetchTimeout,
+
+		definitionName: opts.name,
+	}
+
+	if opts.stickyVariable != "" {
+		group.stickyVariable = opts.engine.VariableFactory().Create(opts.stickyVariable)
+		if group.stickyVariable == nil {
+			core.Logger.Printf("Filter group[%s] unknown sticky variable[%s]\n", opts.name, opts.stickyVariable)
+		} else if group.enableRank {
+			group.variables = append(group.variables, group.stickyVariable)
+		}
+		group.stickyBy = opts.stickyVariable
+		group.stickyInherited = opts.stickyInherited
+		group.stickySalt = opts.stickySalt
+		if group.stickySalt == "" {
+			group.stickySalt = opts.name
+		}
 	}
+
+	return group
 }
 
 type rank struct {
@@ -103,6 +249,84 @@ type rankBoundary struct {
 
 func (f *FilterGroup) Name() string { return f.name }
 
+// MarshalJSON output: [{"name":..,"enable_rank":..,"short_mode":..,"top_k":..,"sticky":{"by":..,"salt":..}}, [$filter]...]
+// Group name is always in metadata, so names of group and filters are kept when it's built again.
+// In rank mode, filter definition is leading with rank options: [{"weight":..,"priority":..}, $filter-items...]
+// json.Marshal escapes "=>" to "=\u003e" like other html characters, which decodes to the same definition,
+// use core.MarshalDefinition to output it as it is.
+func (f *FilterGroup) MarshalJSON() ([]byte, error) {
+	return core.MarshalDefinition(f.definition())
+}
+
+func (f *FilterGroup) definition() []interface{} {
+	items := make([]interface{}, 0, len(f.filters)+1)
+
+	meta := make(map[string]interface{})
+	if f.definitionName != "" {
+		meta["name"] = f.definitionName
+	}
+	if f.enableRank {
+		meta["enable_rank"] = true
+	}
+	// rank mode enables short mode by default
+	if f.shortMode != f.enableRank {
+		meta["short_mode"] = f.shortMode
+	}
+	if f.topK > 0 {
+		meta["top_k"] = f.topK
+	}
+	if f.stickyVariable != nil && !f.stickyInherited {
+		sticky := map[string]interface{}{"by": f.stickyBy}
+		// salt is group name by default
+		if f.stickySalt != f.name {
+			sticky["salt"] = f.stickySalt
+		}
+		meta["sticky"] = sticky
+	}
+	if len(meta) > 0 {
+		items = append(items, meta)
+	}
+
+	offset := len(items)
+	for _, filter := range f.filters {
+		items = append(items, core.Definition(filter))
+	}
+
+	if f.enableRank {
+		for _, rank := range f.ranks {
+			d, ok := f.filters[rank.idx].(definer)
+			if !ok {
+				continue
+			}
+			items[offset+rank.idx] = withRankOptions(d.definition(), rank)
+		}
+	}
+
+	return items
+}
+
+// definer is filter that can output its definition
+type definer interface {
+	definition() []interface{}
+}
+
+// withRankOptions add rank options to leading metadata of definition
+func withRankOptions(definition []interface{}, rank *rank) []interface{} {
+	meta := map[string]interface{}{
+		"weight":   rank.weight,
+		"priority": rank.priority,
+	}
+
+	if m, ok := definition[0].(map[string]interface{}); ok {
+		for key, value := range m {
+			meta[key] = value
+		}
+		definition = definition[1:]
+	}
+
+	return append([]interface{}{meta}, definition...)
+}
+
 func (f *FilterGroup) Run(pctx context.Context, data interface{}) (succ bool) {
 	ctx := core.WithData(pctx, data)
 	trace := ctx.Trace()
@@ -112,11 +336,39 @@ func (f *FilterGroup) Run(pctx context.Context, data interface{}) (succ bool) {
 		idxes[i] = i
 	}
 
+	errs := ctx.ErrorCollector()
+	errCount := errs.Len()
+
+	var result *Result
+	if recorder := getResultRecorder(pctx); recorder != nil {
+		result = recorder.enter(f.name)
+		defer func() {
+			result.Succ = succ
+			result.Errors = errs.Since(errCount)
+			recorder.leave()
+		}()
+	}
+
+	if observer := ctx.Observer(); observer != nil {
+		start := time.Now()
+		defer func() {
+			observer.ObserveFilter(f.name, succ, time.Since(start))
+		}()
+	}
+
+	if f.prefetch {
+		core.Prefetch(ctx, f.variables, f.prefetchTimeout)
+	}
+
 	if trace != nil {
 		trace.Enter("FILTER " + f.Name())
 	}
 
 	if f.enableRank {
+		r := ctx.Rand()
+		if f.stickyVariable != nil {
+			r = core.StickyRand(ctx, f.stickyVariable, f.stickySalt)
+		}
 		// sort filter by priority desc
 		for i, rank := range f.ranks {
 			idxes[i] = rank.idx
@@ -132,11 +384,11 @@ func (f *FilterGroup) Run(pctx context.Context, data interface{}) (succ bool) {
 			totalWeight := b.totalWeight
 			items := make([]Weighter, itemCount)
 			for i := lastIdx; i < b.boundary; i++ {
-				items[i] = f.ranks[i]
+				items[i-lastIdx] = f.ranks[i]
 			}
 
 			for i := 0; i < len(items); i++ {
-				idx := i + PickIndexByWeight(items[i:], totalWeight)
+				idx := i + pickIndexByWeight(r.Int63n, items[i:], totalWeight)
 				items[idx], items[i] = items[i], items[idx]
 				totalWeight -= items[i].Weight()
 			}
@@ -151,9 +403,34 @@ func (f *FilterGroup) Run(pctx context.Context, data interface{}) (succ bool) {
 		if trace != nil {
 			trace.Log("RANK ", idxes)
 		}
+
+		if result != nil {
+			result.Rank = make([]string, len(idxes))
+			for i, idx := range idxes {
+				result.Rank[i] = f.filters[idx].Name()
+			}
+		}
+	}
+
+	limit := f.topK
+	if limit <= 0 && f.shortMode {
+		limit = 1
 	}
+	// collect all matching filters
+	if getMatcher(pctx) != nil {
+		limit = 0
+	}
+	hits := 0
 
 	for _, idx := range idxes {
+		if ctx.Interrupted() {
+			interrupt(ctx, result)
+			if trace != nil {
+				trace.Leave("END "+f.Name()).Log("RET", false)
+			}
+			return false
+		}
+
 		filter := f.filters[idx]
 		if trace != nil {
 			trace.Enter("FILTER " + filter.Name())
@@ -164,9 +441,23 @@ func (f *FilterGroup) Run(pctx context.Context, data interface{}) (succ bool) {
 		if trace != nil {
 			trace.Leave("FILTER "+filter.Name()).Log("RET", isucc)
 		}
+		if interrupted(ctx) {
+			interrupt(ctx, result)
+			if trace != nil {
+				trace.Leave("END "+f.Name()).Log("RET", false)
+			}
+			return false
+		}
+		if errs.Failed(errCount, core.ERROR_ABORT_GROUP) {
+			if trace != nil {
+				trace.Leave("END "+f.Name()).Log("RET", false)
+			}
+			return false
+		}
 		if isucc {
 			succ = isucc
-			if f.shortMode {
+			hits++
+			if limit > 0 && hits >= limit {
 				if trace != nil {
 					trace.Leave("END "+filter.Name()).Log("RET", succ)
 				}
@@ -185,6 +476,7 @@ func (f *FilterGroup) Add(filter Filter, options ...Option) {
 	opts := getFilterOpts(options)
 
 	f.filters = append(f.filters, filter)
+	f.variables = mergeVariables(f.variables, Variables(filter))
 
 	if !f.enableRank {
 		return
@@ -229,12 +521,55 @@ type Options struct {
 	enableRank bool
 	name       string
 	namePrefix string
+	engine     *core.Engine
+
+	prefetch        bool
+	prefetchTimeout time.Duration
+
+	topK int
+
+	stickyVariable  string
+	stickySalt      string
+	stickyInherited bool
 }
 
 type Option interface {
 	apply(*Options)
 }
 
+type optionFunc func(*Options)
+
+func (f optionFunc) apply(opts *Options) {
+	f(opts)
+}
+
+// WithEngine Option, build filter with variables, operations and assignments registered in engine
+func WithEngine(engine *core.Engine) Option {
+	return optionFunc(func(opts *Options) {
+		opts.engine = engine
+	})
+}
+
+// Sticky Option, derive rank of group from value of variable and salt instead of random,
+// e.g. Sticky("ctx.uid", "banner") makes the same user always see the same banner.
+// salt is group name if empty.
+func Sticky(variable, salt string) Option {
+	return optionFunc(func(opts *Options) {
+		opts.stickyVariable = variable
+		opts.stickySalt = salt
+		opts.stickyInherited = false
+	})
+}
+
+// inheritSticky Option, nested group is sticky to variable of parent group, salt is its own name
+func inheritSticky(variable string) Option {
+	return optionFunc(func(opts *Options) {
+		opts.stickyVariable = variable
+		opts.stickySalt = ""
+		opts.stickyInherited = true
+	})
+}
+
 // Weight Option
 type Weight uint64
 
@@ -265,6 +600,14 @@ func (o EnableRank) apply(opts *Options) {
 	opts.shortMode = true
 }
 
+// TopK Option, filter group stops after K filters succeed, e.g. pick up to 3 banners by rank.
+// It takes precedence over ShortMode, which is the same as TopK(1).
+type TopK int
+
+func (o TopK) apply(opts *Options) {
+	opts.topK = int(o)
+}
+
 // Name Option
 type Name string
 
@@ -279,6 +622,68 @@ func (o NamePrefix) apply(opts *Options) {
 	opts.namePrefix = string(o)
 }
 
+// Variables return variables referenced by conditions of filter, nil for custom filter
+func Variables(f Filter) []core.Variable {
+	switch f := f.(type) {
+	case *singleFilter:
+		return f.variables
+	case *FilterGroup:
+		return f.variables
+	}
+
+	return nil
+}
+
+// References return names of filters that use each named condition of library, directly or not.
+//  e.g. {"is_vip": ["page.banner", "page.tips"], "is_night": ["page.tips"]}
+func References(f Filter) map[string][]string {
+	refs := make(map[string][]string)
+
+	var walk func(Filter)
+	walk = func(f Filter) {
+		switch f := f.(type) {
+		case *singleFilter:
+			for _, name := range core.ConditionReferences(f.condition) {
+				refs[name] = append(refs[name], f.name)
+			}
+		case *FilterGroup:
+			for _, filter := range f.filters {
+				walk(filter)
+			}
+		}
+	}
+	walk(f)
+
+	return refs
+}
+
+// mergeVariables append variables that are not in dst
+func mergeVariables(dst, variables []core.Variable) []core.Variable {
+	for _, v := range variables {
+		exists := false
+		for _, d := range dst {
+			if d.Name() == v.Name() {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			dst = append(dst, v)
+		}
+	}
+
+	return dst
+}
+
+// Prefetch Option, resolve cacheable variables concurrently before evaluation.
+// The value is timeout of each variable, no limit if it's not positive.
+type Prefetch time.Duration
+
+func (o Prefetch) apply(opts *Options) {
+	opts.prefetch = true
+	opts.prefetchTimeout = time.Duration(o)
+}
+
 // getFilterOpts return *Options
 func getFilterOpts(opts []Option) *Options {
 	o := &Options{}
@@ -287,6 +692,10 @@ func getFilterOpts(opts []Option) *Options {
 		opt.apply(o)
 	}
 
+	if o.engine == nil {
+		o.engine = core.DefaultEngine()
+	}
+
 	return o
 }
 
@@ -315,6 +724,12 @@ func getFilterOpts(opts []Option) *Options {
 //       ["$var-name", "$op", "$op-value"],  // condition
 //       ["$var-name", "$op", "$op-value"],  // condition
 //       ["$data-key", "$assign", "$assign-value"] // executor, last item
+//     ],
+//     // nested filter group
+//     [
+//       {"name":"$group-name","enable_rank":true,"short_mode":true,"top_k":2,"sticky":{"by":"ctx.uid"}} // group metadata, optional
+//       [$filter],
+//       [$filter]
 //     ]
 //    ]
 //
@@ -322,39 +737,102 @@ func getFilterOpts(opts []Option) *Options {
 //    ShortMode(true)     // enable short mode, only active in group filter
 //    EnableRank(true)    // enable rank mode, and set short mode only active in group filter
 //    Name("filter-name") // specify filter name
+//    Sticky("ctx.uid", "salt") // derive rank from value of variable instead of random, only active in rank mode,
+//                              // nested groups are sticky to the variable too, with their names as salts
+//    WithEngine(engine)  // build filter with specified engine instead of the default one
 //
 func New(items []interface{}, options ...Option) (Filter, error) {
 	if len(items) == 0 {
 		return nil, errors.New("Empty filter")
 	}
 
+	// filter data may be decoded from yaml
+	items = core.ToArray(core.Normalize(items))
+
+	return build(items, options...)
+}
+
+// build build filter group or single filter with data
+func build(items []interface{}, options ...Option) (Filter, error) {
+	if len(items) == 0 {
+		return nil, errors.New("Empty filter")
+	}
+
+	if isGroupData(items) {
+		return buildGroup(items, options...)
+	}
+
+	// single filter with name or metadata
+	if isSingleFilterData(items) {
+		return buildFilter(items, options...)
+	}
+
 	if !core.IsArray(items[0]) {
 		return nil, errors.New("Filter data error,first element is not array")
 	}
 
-	item := core.ToArray(items[0])
-	if len(item) == 0 {
+	if len(core.ToArray(items[0])) == 0 {
 		return nil, errors.New("Filter data error,first element is empty array")
 	}
 
-	// single filter
-	if !core.IsArray(item[0]) {
-		return buildFilter(items, options...)
+	// single filter, first element is condition
+	return buildFilter(items, options...)
+}
+
+// buildGroup build filter group with data, elements are filters or nested groups.
+// [
+//   {"name":..,"enable_rank":..,"short_mode":..,"top_k":..,"sticky":{"by":..,"salt":..},"weight":..,"priority":..} // group metadata, first item, optional
+//   "$group-name"  // group name, first item or after metadata, optional
+//   [$filter],
+//   [$filter-group],
+//   ...
+// ]
+//
+func buildGroup(data []interface{}, options ...Option) (Filter, error) {
+	opts := getFilterOpts(options)
+
+	meta, items, err := parseHeader(opts.engine, data, true)
+	if err != nil {
+		return nil, err
 	}
 
-	group := NewFilterGroup(options...)
+	name := meta.name
+	if name == "" {
+		name = opts.name
+	}
+	if name == "" {
+		name = generateFilterName(data)
+	}
+	// definition outputs name, so names of group and filters are kept when it's built again
+	definitionName := name
+	if opts.namePrefix != "" {
+		name = opts.namePrefix + name
+	}
 
-	if group.name == "" {
-		group.name = generateFilterName(items)
+	group := NewFilterGroup(append(append(options, meta.groupOptions()...), Name(name))...)
+	group.definitionName = definitionName
+	group.weight = meta.weight
+	group.priority = meta.priority
+
+	childOptions := []Option{NamePrefix(group.name + "."), WithEngine(opts.engine)}
+	if group.stickyVariable != nil {
+		childOptions = append(childOptions, inheritSticky(group.stickyBy))
 	}
 
 	for _, item := range items {
 		if !core.IsArray(item) {
 			return nil, errors.New("Filter group data error,element must be array")
 		}
-		if filter, err := buildFilter(core.ToArray(item), NamePrefix(group.name+".")); err != nil {
+		filter, err := build(core.ToArray(item), childOptions...)
+		if err != nil {
 			return nil, err
-		} else {
+		}
+		switch child := filter.(type) {
+		case *singleFilter:
+			group.Add(filter, Weight(child.weight), Priority(child.priority))
+		case *FilterGroup:
+			group.Add(filter, Weight(child.weight), Priority(child.priority))
+		default:
 			group.Add(filter)
 		}
 	}
@@ -362,9 +840,367 @@ func New(items []interface{}, options ...Option) (Filter, error) {
 	return group, nil
 }
 
+// NewFromYAML build filter with yaml data, the data struct is the same as New.
+//  e.g.
+//    - [ctx.foo, in, [a, b]]
+//    - [banner, +, {src: "https://example.com/banner.png"}]
+//
+func NewFromYAML(data []byte, options ...Option) (Filter, error) {
+	var items []interface{}
+
+	if err := yaml.Unmarshal(data, &items); err != nil {
+		return nil, errors.Wrap(err, "yaml")
+	}
+
+	return New(items, options...)
+}
+
+// Validate check filter definition data like New does, but walk the whole definition
+// and return all problems with their locations instead of failing on the first one.
+// Returns nil if definition is valid, otherwise core.ValidationErrors.
+//  e.g.
+//    [2][1][2] INVALID_VALUE: [between] operation value must be a list with 2 elements
+//
+func Validate(items []interface{}, options ...Option) error {
+	errs := make(core.ValidationErrors, 0)
+	engine := getFilterOpts(options).engine
+
+	if len(items) == 0 {
+		errs.Add("", items, core.ERR_EMPTY, "Empty filter")
+		return errs
+	}
+
+	items = core.ToArray(core.Normalize(items))
+
+	if isGroupData(items) {
+		validateGroup(engine, items, "", &errs)
+	} else if isSingleFilterData(items) {
+		validateFilter(engine, items, "", &errs)
+	} else if !core.IsArray(items[0]) {
+		errs.Add("[0]", items[0], core.ERR_NOT_ARRAY, "Filter data error,first element is not array")
+	} else if item := core.ToArray(items[0]); len(item) == 0 {
+		errs.Add("[0]", items[0], core.ERR_EMPTY, "Filter data error,first element is empty array")
+	} else {
+		validateFilter(engine, items, "", &errs)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// validateGroup check filter group definition, see buildGroup
+func validateGroup(engine *core.Engine, data []interface{}, path string, errs *core.ValidationErrors) {
+	_, items, err := parseHeader(engine, data, true)
+	if err != nil {
+		errs.Add(path+"[0]", data[0], core.ERR_INVALID_VALUE, "%s", err)
+	}
+	offset := len(data) - len(items)
+
+	for i := offset; i < len(data); i++ {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if !core.IsArray(data[i]) {
+			errs.Add(itemPath, data[i], core.ERR_NOT_ARRAY, "Filter group data error,element must be array")
+			continue
+		}
+		item := core.ToArray(data[i])
+		if len(item) > 0 && isGroupData(item) {
+			validateGroup(engine, item, itemPath, errs)
+		} else {
+			validateFilter(engine, item, itemPath, errs)
+		}
+	}
+}
+
+// validateFilter check single filter definition, see buildFilter
+func validateFilter(engine *core.Engine, data []interface{}, path string, errs *core.ValidationErrors) {
+	if len(data) == 0 {
+		errs.Add(path, data, core.ERR_EMPTY, "Filter struct is empty")
+		return
+	}
+
+	_, items, err := parseHeader(engine, data, false)
+	if err != nil {
+		errs.Add(path+"[0]", data[0], core.ERR_INVALID_VALUE, "%s", err)
+	}
+	offset := len(data) - len(items)
+
+	if len(data)-offset < 2 {
+		errs.Add(path, data, core.ERR_ELEMENT_COUNT, "Filter struct must contain conditions and assigment")
+		return
+	}
+
+	last := len(data) - 1
+	for i := offset; i < last; i++ {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if !core.IsArray(data[i]) {
+			errs.Add(itemPath, data[i], core.ERR_NOT_ARRAY, "Sub item must be an array. -> %v", data[i])
+			continue
+		}
+		*errs = append(*errs, engine.ValidateCondition(core.ToArray(data[i]), core.LOGIC_ALL).Prefix(itemPath)...)
+	}
+
+	if data[last] == nil {
+		return
+	}
+
+	itemPath := fmt.Sprintf("%s[%d]", path, last)
+	if !core.IsArray(data[last]) {
+		errs.Add(itemPath, data[last], core.ERR_NOT_ARRAY, "Executor item is not array. -> %v", data[last])
+		return
+	}
+	*errs = append(*errs, engine.ValidateExecutor(core.ToArray(data[last])).Prefix(itemPath)...)
+}
+
+// isFilterData check if item is filter data instead of condition data.
+// filter data's first element is a condition array, a metadata object, or a name string followed by a condition array.
+func isFilterData(item []interface{}) bool {
+	if len(item) == 0 {
+		return false
+	}
+
+	if core.IsArray(item[0]) {
+		return true
+	}
+
+	// leading filter metadata
+	if _, ok := item[0].(map[string]interface{}); ok {
+		return true
+	}
+
+	_, ok := item[0].(string)
+
+	return ok && len(item) > 1 && core.IsArray(item[1])
+}
+
+// isGroupData check if items is data of filter group,
+// its first element after optional metadata and name is filter data.
+func isGroupData(items []interface{}) bool {
+	offset := 0
+	if _, ok := items[0].(map[string]interface{}); ok {
+		offset++
+	}
+	if len(items) > offset {
+		if _, ok := items[offset].(string); ok {
+			offset++
+		}
+	}
+	if len(items) <= offset || !core.IsArray(items[offset]) {
+		return false
+	}
+
+	return isFilterData(core.ToArray(items[offset]))
+}
+
+// isSingleFilterData check if items is data of single filter that starts with name or metadata
+func isSingleFilterData(items []interface{}) bool {
+	switch items[0].(type) {
+	case string, map[string]interface{}:
+		return true
+	}
+
+	return false
+}
+
+// filterMeta is metadata of filter in definition, e.g. {"name":"f1","weight":10,"priority":1}
+// Filter group also accepts group options, e.g. {"name":"slot","enable_rank":true,"top_k":2,"sticky":{"by":"ctx.uid"}}
+type filterMeta struct {
+	name     string
+	weight   int64
+	priority int64
+
+	// group options
+	enableRank   bool
+	shortMode    bool
+	hasShortMode bool
+	topK         int64
+	stickyBy     string
+	stickySalt   string
+	groupKey     string // one of group options in metadata
+}
+
+func parseFilterMeta(m map[string]interface{}) (*filterMeta, error) {
+	meta := &filterMeta{}
+
+	for key, value := range m {
+		switch key {
+		case "name":
+			name, ok := value.(string)
+			if !ok || name == "" {
+				return nil, errors.Errorf("Filter metadata name must be non-empty string. -> %v", value)
+			}
+			meta.name = name
+		case "weight", "priority", "top_k":
+			n, ok := metaInt(value)
+			if !ok {
+				return nil, errors.Errorf("Filter metadata %s must be non-negative integer. -> %v", key, value)
+			}
+			switch key {
+			case "weight":
+				meta.weight = n
+			case "priority":
+				meta.priority = n
+			default:
+				meta.topK = n
+				meta.groupKey = key
+			}
+		case "enable_rank", "short_mode":
+			b, ok := value.(bool)
+			if !ok {
+				return nil, errors.Errorf("Filter metadata %s must be bool. -> %v", key, value)
+			}
+			if key == "enable_rank" {
+				meta.enableRank = b
+			} else {
+				meta.shortMode, meta.hasShortMode = b, true
+			}
+			meta.groupKey = key
+		case "sticky":
+			if err := meta.parseSticky(value); err != nil {
+				return nil, err
+			}
+			meta.groupKey = key
+		default:
+			return nil, errors.Errorf("Unknown filter metadata[%s]", key)
+		}
+	}
+
+	return meta, nil
+}
+
+// parseSticky parse sticky option of group: {"by":"ctx.uid","salt":"exp1"}, salt is optional
+func (m *filterMeta) parseSticky(value interface{}) error {
+	sticky, ok := value.(map[string]interface{})
+	if !ok {
+		return errors.Errorf("Filter metadata sticky must be object. -> %v", value)
+	}
+
+	for key, value := range sticky {
+		switch key {
+		case "by":
+			if m.stickyBy, ok = value.(string); !ok || m.stickyBy == "" {
+				return errors.Errorf("Filter metadata sticky.by must be variable name. -> %v", value)
+			}
+		case "salt":
+			if m.stickySalt, ok = value.(string); !ok {
+				return errors.Errorf("Filter metadata sticky.salt must be string. -> %v", value)
+			}
+		default:
+			return errors.Errorf("Unknown filter metadata[sticky.%s]", key)
+		}
+	}
+
+	if m.stickyBy == "" {
+		return errors.New("Filter metadata sticky.by is required")
+	}
+
+	return nil
+}
+
+// checkGroup check if metadata is valid for filter group
+func (m *filterMeta) checkGroup(engine *core.Engine) error {
+	if m.stickyBy != "" && engine.VariableFactory().Create(m.stickyBy) == nil {
+		return errors.Errorf("Unknown sticky variable[%s]", m.stickyBy)
+	}
+
+	return nil
+}
+
+// checkFilter check if metadata is valid for single filter
+func (m *filterMeta) checkFilter() error {
+	if m.groupKey != "" {
+		return errors.Errorf("Filter metadata[%s] is only for filter group", m.groupKey)
+	}
+
+	return nil
+}
+
+// groupOptions return options of group in metadata, short_mode is applied after enable_rank
+func (m *filterMeta) groupOptions() []Option {
+	options := make([]Option, 0, 4)
+
+	if m.enableRank {
+		options = append(options, EnableRank(true))
+	}
+	if m.hasShortMode {
+		options = append(options, ShortMode(m.shortMode))
+	}
+	if m.topK > 0 {
+		options = append(options, TopK(m.topK))
+	}
+	if m.stickyBy != "" {
+		options = append(options, Sticky(m.stickyBy, m.stickySalt))
+	}
+
+	return options
+}
+
+// parseHeader parse leading metadata and name of definition, return the rest items,
+// and check metadata for filter group or single filter.
+// It's shared by build and Validate, the rest items are returned even if header is invalid.
+func parseHeader(engine *core.Engine, data []interface{}, group bool) (*filterMeta, []interface{}, error) {
+	var (
+		meta = &filterMeta{}
+		err  error
+	)
+
+	if m, ok := data[0].(map[string]interface{}); ok {
+		if meta, err = parseFilterMeta(m); err != nil {
+			meta = &filterMeta{}
+		}
+		data = data[1:]
+	}
+
+	if len(data) > 0 {
+		if name, ok := data[0].(string); ok {
+			if err == nil && meta.name != "" && meta.name != name {
+				err = errors.Errorf("Filter name[%s] conflicts with metadata name[%s]", name, meta.name)
+			}
+			meta.name = name
+			data = data[1:]
+		}
+	}
+
+	if err == nil {
+		if group {
+			err = meta.checkGroup(engine)
+		} else {
+			err = meta.checkFilter()
+		}
+	}
+
+	return meta, data, err
+}
+
+// metaInt convert number from json or yaml to non-negative int64
+func metaInt(value interface{}) (int64, bool) {
+	var n int64
+
+	switch v := value.(type) {
+	case int:
+		n = int64(v)
+	case int64:
+		n = v
+	case uint64:
+		n = int64(v)
+	case float64:
+		if v != float64(int64(v)) {
+			return 0, false
+		}
+		n = int64(v)
+	default:
+		return 0, false
+	}
+
+	return n, n >= 0
+}
+
 // buildFilter build filter with data.
 // [
-//   "$filter-name"  // filter name, first item, optional
+//   {"name":..,"weight":..,"priority":..} // filter metadata, first item, optional
+//   "$filter-name"  // filter name, first item or after metadata, optional
 //   ["$var-name", "$op", "$op-value"],  // condition
 //   ["$var-name", "$op", "$op-value"],  // condition
 //   ["$data-key", "$assign", "$assign-value"] // executor, last item
@@ -377,17 +1213,21 @@ func buildFilter(data []interface{}, options ...Option) (Filter, error) {
 
 	opts := getFilterOpts(options)
 
-	// filter name
-	name, ok := data[0].(string)
-	if ok {
-		data = data[1:]
-	} else {
+	// filter metadata and name
+	meta, data, err := parseHeader(opts.engine, data, false)
+	if err != nil {
+		return nil, err
+	}
+
+	name := meta.name
+	if name == "" {
 		if opts.name != "" {
 			name = opts.name
 		} else {
 			name = generateFilterName(data)
 		}
 	}
+	definitionName := name
 
 	if opts.namePrefix != "" {
 		name = opts.namePrefix + name
@@ -398,17 +1238,23 @@ func buildFilter(data []interface{}, options ...Option) (Filter, error) {
 	}
 
 	filter := &singleFilter{
-		name: name,
+		name:            name,
+		definitionName:  definitionName,
+		weight:          meta.weight,
+		priority:        meta.priority,
+		prefetch:        opts.prefetch,
+		prefetchTimeout: opts.prefetchTimeout,
 	}
 
-	if condition, err := core.NewCondition(data[:len(data)-1], core.LOGIC_ALL); err != nil {
+	if condition, err := opts.engine.NewCondition(data[:len(data)-1], core.LOGIC_ALL); err != nil {
 		return nil, errors.Wrap(err, "condition")
 	} else {
 		filter.condition = condition
+		filter.variables = core.ConditionVariables(condition)
 	}
 
 	if data[len(data)-1] != nil {
-		if executor, err := core.NewExecutor(data[len(data)-1:]); err != nil {
+		if executor, err := opts.engine.NewExecutor(data[len(data)-1:]); err != nil {
 			return nil, errors.Wrap(err, "executor")
 		} else {
 			filter.executor = executor
@@ -1,7 +1,13 @@
 package filter
 
 import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -134,3 +140,523 @@ func TestNew(t *testing.T) {
 	require.True(t, hit[2] < hit[1], "hit.2 < hit.1")
 	t.Log("hit:", hit)
 }
+
+func TestMarshalJSON(t *testing.T) {
+	def := `[["f1",["ctx.foo","=","bar"],["any?","=>",[["ctx.bar","in","a,b"]]],["a","=",1]],["f2",["succ","=",true],null]]`
+
+	var items []interface{}
+	require.NoError(t, json.Unmarshal([]byte(def), &items))
+	f, err := New(items, Name("group"))
+	require.NoError(t, err)
+
+	b, err := json.Marshal(f)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"name":"group"},`+def[1:], string(b))
+
+	f1, err := New(arr(
+		"f1",
+		arr("succ", "=", true),
+		arr("a", "=", 1),
+	))
+	require.NoError(t, err)
+	g := NewFilterGroup(EnableRank(true))
+	g.Add(f1, Weight(10), Priority(3))
+	b, err = json.Marshal(g)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"enable_rank":true},[{"priority":3,"weight":10},"f1",["succ","=",true],["a","=",1]]]`, string(b))
+
+	// names and group options are kept in round trip
+	for _, options := range [][]Option{
+		{},
+		{Name("group")},
+		{EnableRank(true)},
+		{EnableRank(true), ShortMode(false)},
+		{ShortMode(true)},
+		{TopK(2)},
+	} {
+		f, err := New(arr(
+			arr(map[string]interface{}{"weight": 10, "priority": 2}, arr("succ", "=", true), arr("a", "=", 1)),
+			arr(map[string]interface{}{"weight": 20, "priority": 1}, arr("any?", "=>", arr(arr("succ", "=", true))), arr("b", "=", 1)),
+		), options...)
+		require.NoError(t, err)
+		b, err := json.Marshal(f)
+		require.NoError(t, err)
+
+		var items []interface{}
+		require.NoError(t, json.Unmarshal(b, &items))
+		f1, err := New(items)
+		require.NoError(t, err)
+		b1, err := json.Marshal(f1)
+		require.NoError(t, err)
+		assert.Equal(t, string(b), string(b1))
+
+		g, g1 := f.(*FilterGroup), f1.(*FilterGroup)
+		assert.Equal(t, g.Name(), g1.Name(), string(b))
+		for i := range g.filters {
+			assert.Equal(t, g.filters[i].Name(), g1.filters[i].Name(), string(b))
+		}
+		assert.Equal(t, g.enableRank, g1.enableRank, string(b))
+		assert.Equal(t, g.shortMode, g1.shortMode, string(b))
+		assert.Equal(t, g.topK, g1.topK, string(b))
+	}
+
+	// json.Marshal escapes html characters, MarshalDefinition doesn't
+	b, err = json.Marshal(f)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"=\u003e"`)
+	b, err = core.MarshalDefinition(f)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"=>"`)
+
+	// custom filter without definition
+	g = NewFilterGroup()
+	g.Add(customFilter{})
+	_, err = json.Marshal(g)
+	assert.Error(t, err)
+}
+
+type customFilter struct{}
+
+func (customFilter) Name() string                              { return "custom" }
+func (customFilter) Run(_ context.Context, _ interface{}) bool { return true }
+
+func TestFilterMeta(t *testing.T) {
+	def := `[[{"priority":3,"weight":10},"f1",["succ","=",true],["a","=",1]],[{"priority":1,"weight":100,"name":"f2"},["succ","=",true],["a","=",2]]]`
+
+	var items []interface{}
+	require.NoError(t, json.Unmarshal([]byte(def), &items))
+	f, err := New(items, EnableRank(true))
+	require.NoError(t, err)
+
+	b, err := json.Marshal(f)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"enable_rank":true,"name":"`+f.Name()+`"},[{"priority":3,"weight":10},"f1",["succ","=",true],["a","=",1]],[{"priority":1,"weight":100},"f2",["succ","=",true],["a","=",2]]]`, string(b))
+
+	// f1 has higher priority
+	for i := 0; i < 100; i++ {
+		data := make(map[string]interface{})
+		require.True(t, f.Run(core.NewContext(), data))
+		assert.Equal(t, float64(1), data["a"])
+	}
+
+	// two priority tiers of two filters each
+	f, err = New(arr(
+		arr(map[string]interface{}{"weight": 10, "priority": 2}, arr("succ", "=", true), arr("a", "=", 1)),
+		arr(map[string]interface{}{"weight": 10, "priority": 2}, arr("succ", "=", true), arr("a", "=", 2)),
+		arr(map[string]interface{}{"weight": 10, "priority": 1}, arr("succ", "=", true), arr("b", "=", 1)),
+		arr(map[string]interface{}{"weight": 10, "priority": 1}, arr("succ", "=", true), arr("b", "=", 2)),
+	), EnableRank(true), ShortMode(false))
+	require.NoError(t, err)
+	hit := make(map[interface{}]int)
+	for i := 0; i < 1000; i++ {
+		ctx := core.NewContext()
+		data := make(map[string]interface{})
+		result := RunWithResult(ctx, f, data)
+		require.True(t, result.Succ)
+		require.Len(t, result.Rank, 4)
+		hit[data["b"]]++
+	}
+	assert.True(t, hit[1] > 0 && hit[2] > 0, "hit:%v", hit)
+
+	// metadata of single filter
+	f, err = New(arr(
+		map[string]interface{}{"name": "single"},
+		arr("succ", "=", true),
+		arr("a", "=", 1),
+	))
+	require.NoError(t, err)
+	assert.Equal(t, "single", f.(*singleFilter).name)
+
+	for _, meta := range []map[string]interface{}{
+		{"weight": -1},
+		{"weight": 1.5},
+		{"priority": "1"},
+		{"name": 1},
+		{"foo": 1},
+	} {
+		items := arr(arr(meta, arr("succ", "=", true), nil))
+		_, err = New(items)
+		assert.Error(t, err, "%v", meta)
+		assert.Error(t, Validate(items), "%v", meta)
+	}
+
+	// Validate agrees with New on conflicting names
+	for _, items := range [][]interface{}{
+		arr(arr(map[string]interface{}{"name": "f1"}, "f2", arr("succ", "=", true), nil)),
+		arr(map[string]interface{}{"name": "a"}, "b", arr(arr("succ", "=", true), nil), arr(arr("succ", "=", true), nil)),
+	} {
+		_, err = New(items)
+		assert.Error(t, err, "conflicting names")
+		err = Validate(items)
+		require.Error(t, err, "conflicting names")
+		assert.Equal(t, "[0]", err.(core.ValidationErrors)[0].Path[len(err.(core.ValidationErrors)[0].Path)-3:])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(arr(
+		arr("ctx.foo", "=", "bar"),
+		arr("a", "=", 1),
+	)))
+
+	err := Validate(arr(
+		arr(
+			"f1",
+			arr("ctx.foo", "=", "bar"),
+			arr("a", "=", 1),
+		),
+		arr(
+			arr("ctx.foo", "between", 1),
+			arr("ctx.bar", "~", ""),
+			arr("a", "+", 1),
+		),
+		"foo",
+		arr(
+			arr("ctx.foo", "=", "bar"),
+		),
+	))
+	require.Error(t, err)
+	errs, ok := err.(core.ValidationErrors)
+	require.True(t, ok)
+
+	paths := make([]string, len(errs))
+	for i, e := range errs {
+		paths[i] = e.Path
+	}
+	assert.Equal(t, []string{"[1][0][2]", "[1][1][2]", "[1][2][2]", "[2]", "[3]"}, paths, err.Error())
+}
+
+func TestNewFromYAML(t *testing.T) {
+	ctx := core.NewContext()
+	ctx.Set("foo", "a")
+
+	_, err := NewFromYAML([]byte(`
+- name: banner
+  filter:
+    - [ctx.foo, in, [a, b]]
+    - [banner, +, {src: "https://example.com/banner.png", size: {w: 10}}]
+- name: none
+  filter:
+    - [ctx.foo, "=", c]
+    - [banner, "=", none]
+`))
+	assert.Error(t, err, "filter data must be list of filters")
+
+	f, err := NewFromYAML([]byte(`
+- - [ctx.foo, in, [a, b]]
+  - [banner, +, {src: "https://example.com/banner.png", size: {w: 10}}]
+- - [ctx.foo, "=", c]
+  - [banner, "=", none]
+`))
+	require.NoError(t, err)
+
+	data := map[string]interface{}{
+		"banner": map[string]interface{}{"type": "image"},
+	}
+	require.True(t, f.Run(ctx, data))
+	assert.Equal(t, map[string]interface{}{
+		"type": "image",
+		"src":  "https://example.com/banner.png",
+		"size": map[string]interface{}{"w": 10},
+	}, data["banner"])
+
+	// filter data decoded by yaml decoders that produce map[interface{}]interface{}
+	f, err = New(arr(
+		arr("ctx.foo", "=", "a"),
+		arr("banner", "+", map[interface{}]interface{}{"src": "b.png"}),
+	))
+	require.NoError(t, err)
+	require.True(t, f.Run(ctx, data))
+	assert.Equal(t, "b.png", data["banner"].(map[string]interface{})["src"])
+}
+
+func TestNewWithEngine(t *testing.T) {
+	engine := core.NewEngine(core.InheritDefault())
+	engine.VariableFactory().Register(
+		core.SingletonVariableCreator(core.NewSimpleVariable("product", core.Cacheable, &core.StaticValue{Val: "p1"})),
+		"product",
+	)
+
+	items := arr(
+		arr(
+			arr("product", "=", "p1"),
+			arr("a", "=", 1),
+		),
+	)
+
+	_, err := New(items)
+	assert.Error(t, err)
+	assert.Error(t, Validate(items))
+
+	f, err := New(items, WithEngine(engine))
+	require.NoError(t, err)
+	assert.NoError(t, Validate(items, WithEngine(engine)))
+
+	data := make(map[string]interface{})
+	require.True(t, f.Run(core.NewContext(), data))
+	assert.Equal(t, 1, data["a"])
+}
+
+func TestObserver(t *testing.T) {
+	metrics := core.NewMetrics()
+	ctx := core.WithContext(context.Background(), core.WithObserver(metrics))
+
+	f, err := New(arr(
+		arr("f1", arr("succ", "=", false), nil),
+		arr("f2", arr("succ", "=", true), nil),
+	), Name("g"))
+	require.NoError(t, err)
+
+	f.Run(ctx, nil)
+	f.Run(ctx, nil)
+
+	snapshot := metrics.Snapshot()[core.METRIC_FILTER]
+	assert.Equal(t, core.MetricStat{Count: 2, Hits: 2, Duration: snapshot["g"].Duration}, snapshot["g"])
+	assert.Equal(t, int64(0), snapshot["g.f1"].Hits)
+	assert.Equal(t, int64(2), snapshot["g.f2"].Hits)
+}
+
+func TestPrefetch(t *testing.T) {
+	var (
+		barrier sync.WaitGroup
+		calls   int32
+	)
+	barrier.Add(2)
+	value := core.ValueFunc(func(_ *core.Context) interface{} {
+		atomic.AddInt32(&calls, 1)
+		barrier.Done()
+		done := make(chan struct{})
+		go func() {
+			barrier.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return true
+		case <-time.After(time.Second):
+			return false
+		}
+	})
+
+	engine := core.NewEngine(core.InheritDefault())
+	engine.VariableFactory().Register(core.SingletonVariableCreator(core.NewSimpleVariable("v1", core.Cacheable, value)), "v1")
+	engine.VariableFactory().Register(core.SingletonVariableCreator(core.NewSimpleVariable("v2", core.Cacheable, value)), "v2")
+
+	items := arr(
+		arr("f1", arr("v1", "=", true), arr("succ", "=", true), arr("a", "=", 1)),
+		arr("f2", arr("v2", "=", true), arr("v1", "=", true), arr("b", "=", 1)),
+	)
+
+	f, err := New(items, WithEngine(engine), Prefetch(time.Second))
+	require.NoError(t, err)
+
+	names := make([]string, 0)
+	for _, v := range Variables(f) {
+		names = append(names, v.Name())
+	}
+	assert.Equal(t, []string{"v1", "succ", "v2"}, names)
+
+	data := make(map[string]interface{})
+	assert.True(t, f.Run(context.Background(), data))
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 1}, data, "variables are resolved concurrently")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// slow variable is resolved once, and Run waits for it at most timeout
+	var slowCalls int32
+	engine.VariableFactory().Register(core.SingletonVariableCreator(core.NewSimpleVariable("slow", core.Cacheable, core.ValueFunc(func(_ *core.Context) interface{} {
+		atomic.AddInt32(&slowCalls, 1)
+		time.Sleep(200 * time.Millisecond)
+		return true
+	}))), "slow")
+	f, err = New(arr(arr("slow", "=", true), arr("a", "=", 1)), WithEngine(engine), Prefetch(10*time.Millisecond))
+	require.NoError(t, err)
+
+	collector := core.NewErrorCollector(core.ERROR_AS_FALSE)
+	ctx := core.WithContext(context.Background(), core.WithErrorCollector(collector))
+	start := time.Now()
+	assert.False(t, f.Run(ctx, make(map[string]interface{})))
+	assert.Less(t, int64(time.Since(start)), int64(100*time.Millisecond))
+	assert.Equal(t, 1, collector.Len())
+	time.Sleep(250 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&slowCalls))
+}
+
+func TestStickyRank(t *testing.T) {
+	items := arr(
+		arr(map[string]interface{}{"name": "a", "weight": 50}, arr("succ", "=", true), arr("v", "=", "a")),
+		arr(map[string]interface{}{"name": "b", "weight": 50}, arr("succ", "=", true), arr("v", "=", "b")),
+	)
+
+	f, err := New(items, Name("banner"), EnableRank(true), Sticky("ctx.uid", ""))
+	require.NoError(t, err)
+
+	hit := make(map[string]int)
+	for uid := 0; uid < 1000; uid++ {
+		ctx := core.NewContext()
+		ctx.Set("uid", uid)
+		data := make(map[string]interface{})
+		require.True(t, f.Run(ctx, data))
+		first := data["v"]
+		for i := 0; i < 5; i++ {
+			f.Run(ctx, data)
+			require.Equal(t, first, data["v"], "uid %d", uid)
+		}
+		hit[first.(string)]++
+	}
+	assert.True(t, hit["a"] > 400 && hit["b"] > 400, "hit:%v", hit)
+
+	// reproducible rank with WithRand
+	f, err = New(items, EnableRank(true))
+	require.NoError(t, err)
+	run := func() []string {
+		ctx := core.WithContext(context.Background(), core.WithRand(rand.New(rand.NewSource(1))))
+		values := make([]string, 10)
+		for i := range values {
+			data := make(map[string]interface{})
+			f.Run(ctx, data)
+			values[i] = data["v"].(string)
+		}
+		return values
+	}
+	assert.Equal(t, run(), run())
+
+	// sticky in group metadata, nested groups inherit variable of parent
+	def := `[{"name":"page","enable_rank":true,"short_mode":false,"sticky":{"by":"ctx.uid","salt":"exp1"}},` +
+		`[{"name":"slot","enable_rank":true,"weight":1,"priority":2},` +
+		`[{"weight":50,"priority":0},"a",["succ","=",true],["v","=","a"]],` +
+		`[{"weight":50,"priority":0},"b",["succ","=",true],["v","=","b"]]],` +
+		`[{"weight":1,"priority":1},"tips",["succ","=",true],["tips","=",true]]]`
+	var defItems []interface{}
+	require.NoError(t, json.Unmarshal([]byte(def), &defItems))
+	require.NoError(t, Validate(defItems))
+	f, err = New(defItems)
+	require.NoError(t, err)
+	g := f.(*FilterGroup)
+	require.NotNil(t, g.stickyVariable)
+	assert.Equal(t, "exp1", g.stickySalt)
+	slot := g.filters[0].(*FilterGroup)
+	require.NotNil(t, slot.stickyVariable, "inherited")
+	assert.Equal(t, "page.slot", slot.stickySalt)
+
+	b, err := f.(json.Marshaler).MarshalJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, def, string(b))
+
+	hit = make(map[string]int)
+	for uid := 0; uid < 1000; uid++ {
+		ctx := core.NewContext()
+		ctx.Set("uid", uid)
+		data := make(map[string]interface{})
+		require.True(t, f.Run(ctx, data))
+		first := data["v"]
+		for i := 0; i < 5; i++ {
+			f.Run(ctx, data)
+			require.Equal(t, first, data["v"], "uid %d", uid)
+		}
+		hit[first.(string)]++
+	}
+	assert.True(t, hit["a"] > 400 && hit["b"] > 400, "hit:%v", hit)
+
+	for _, sticky := range []interface{}{
+		"ctx.uid",
+		map[string]interface{}{"salt": "exp1"},
+		map[string]interface{}{"by": "ctx.uid", "salt": 1},
+		map[string]interface{}{"by": "ctx.uid", "foo": 1},
+		map[string]interface{}{"by": "unknown"},
+	} {
+		items := arr(
+			map[string]interface{}{"enable_rank": true, "sticky": sticky},
+			arr(arr("succ", "=", true), nil),
+		)
+		_, err = New(items)
+		assert.Error(t, err, "%v", sticky)
+		assert.Error(t, Validate(items), "%v", sticky)
+	}
+	_, err = New(arr(arr(map[string]interface{}{"sticky": map[string]interface{}{"by": "ctx.uid"}}, arr("succ", "=", true), nil)))
+	assert.Error(t, err, "sticky is only for group")
+}
+
+func TestNestedGroup(t *testing.T) {
+	def := `[
+		{"name":"page"},
+		[{"name":"slot","enable_rank":true},
+			[{"weight":10,"priority":2},"b1",["succ","=",true],["banner","=","b1"]],
+			[{"weight":10,"priority":1},"b2",["succ","=",true],["banner","=","b2"]]
+		],
+		[{"name":"tips","top_k":1},
+			["t1",["succ","=",false],["tips","=","t1"]],
+			["t2",["succ","=",true],["tips","=","t2"]],
+			["t3",["succ","=",true],["tips","=","t3"]]
+		],
+		["footer",["succ","=",true],["footer","=",true]]
+	]`
+
+	var items []interface{}
+	require.NoError(t, json.Unmarshal([]byte(def), &items))
+	require.NoError(t, Validate(items))
+	f, err := New(items)
+	require.NoError(t, err)
+	assert.Equal(t, "page", f.Name())
+
+	ctx := core.NewContext()
+	data := make(map[string]interface{})
+	result := RunWithResult(ctx, f, data)
+	require.True(t, result.Succ)
+	assert.Equal(t, map[string]interface{}{"banner": "b1", "tips": "t2", "footer": true}, data)
+	assert.Equal(t, []string{"page.slot.b1", "page.tips.t2", "page.footer"}, result.Fired)
+
+	b, err := json.Marshal(f)
+	require.NoError(t, err)
+	assert.JSONEq(t, def, string(b))
+
+	// group options in metadata of single filter
+	_, err = New(arr(arr(map[string]interface{}{"top_k": 1}, arr("succ", "=", true), nil), arr(arr("succ", "=", true), nil)))
+	assert.Error(t, err)
+
+	err = Validate(arr(
+		arr(map[string]interface{}{"name": "g", "short_mode": 1},
+			arr(arr("succ", "=", true), arr("a", "=", 1)),
+			arr(arr("ctx.foo", "between", 1), arr("a", "=", 1)),
+			"foo",
+		),
+	))
+	require.Error(t, err)
+	errs := err.(core.ValidationErrors)
+	paths := make([]string, len(errs))
+	for i, e := range errs {
+		paths[i] = e.Path
+	}
+	assert.Equal(t, []string{"[0][0]", "[0][2][0][2]", "[0][3]"}, paths, err.Error())
+}
+
+func TestReferences(t *testing.T) {
+	engine := core.NewEngine(core.InheritDefault())
+	require.NoError(t, engine.ConditionLibrary().Define(map[string]interface{}{
+		"is_vip":       arr("data.level", ">=", 3),
+		"is_night":     arr("ctx.hour", ">=", 18),
+		"is_vip_night": arr(arr("use?", "=", "is_vip"), arr("use?", "=", "is_night")),
+	}))
+
+	f, err := New(arr(
+		arr("banner", arr("@is_vip", "=", true), arr("banner", "=", "vip")),
+		arr("tips", arr("use?", "=", "is_vip_night"), arr("tips", "=", "night")),
+		arr("footer", arr("succ", "=", true), arr("footer", "=", true)),
+	), Name("page"), WithEngine(engine))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string][]string{
+		"is_vip":       {"page.banner", "page.tips"},
+		"is_vip_night": {"page.tips"},
+		"is_night":     {"page.tips"},
+	}, References(f))
+
+	ctx := core.NewContext()
+	ctx.Set("hour", 20)
+	data := map[string]interface{}{"level": 3}
+	require.True(t, f.Run(ctx, data))
+	assert.Equal(t, "vip", data["banner"])
+	assert.Equal(t, "night", data["tips"])
+
+	_, err = New(arr(arr("use?", "=", "is_vip"), nil))
+	assert.Error(t, err, "not defined in default engine")
+}
@@ -9,4 +9,5 @@ require (
 	github.com/stretchr/testify v1.6.1
 	github.com/techxmind/go-utils v0.0.0-20201127043211-03b94e0bd51e
 	github.com/techxmind/ip2location v0.0.0-20201016120605-9ca74285b024
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
@@ -0,0 +1,54 @@
+package filter
+
+import (
+	"context"
+
+	"github.com/techxmind/filter/core"
+)
+
+const interruptionCtxKey ctxKey = "interruption"
+
+// interruption records the reason why the run is cut short
+type interruption struct {
+	err error
+}
+
+func getInterruption(ctx context.Context) *interruption {
+	if i := ctx.Value(interruptionCtxKey); i != nil {
+		return i.(*interruption)
+	}
+
+	return nil
+}
+
+// RunE run filter, return ctx.Err() if the run is cut short by cancellation or deadline.
+// Filter that is cut short returns false and none of its executors are applied,
+// filter group that is cut short returns false, filters succeeded already are not rolled back.
+func RunE(ctx context.Context, f Filter, data interface{}) (bool, error) {
+	i := &interruption{}
+	succ := f.Run(context.WithValue(ctx, interruptionCtxKey, i), data)
+
+	return succ, i.err
+}
+
+// interrupt mark the run is cut short
+func interrupt(ctx *core.Context, result *Result) {
+	if trace := ctx.Trace(); trace != nil {
+		trace.Log("INTERRUPTED", ctx.Err())
+	}
+
+	if i := getInterruption(ctx); i != nil && i.err == nil {
+		i.err = ctx.Err()
+	}
+
+	if result != nil {
+		result.Interrupted = true
+	}
+}
+
+// interrupted report whether filter run by the ctx is cut short
+func interrupted(ctx context.Context) bool {
+	i := getInterruption(ctx)
+
+	return i != nil && i.err != nil
+}
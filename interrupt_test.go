@@ -0,0 +1,59 @@
+package filter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techxmind/filter/core"
+)
+
+func TestRunE(t *testing.T) {
+	var cancel context.CancelFunc
+
+	engine := core.NewEngine(core.InheritDefault())
+	engine.VariableFactory().Register(
+		core.SingletonVariableCreator(core.NewSimpleVariable("cancel", core.Uncacheable, core.ValueFunc(func(_ *core.Context) interface{} {
+			cancel()
+			return true
+		}))),
+		"cancel",
+	)
+
+	f, err := New(arr(
+		arr("f1", arr("succ", "=", true), arr("a", "=", 1)),
+		arr("f2", arr("cancel", "=", true), arr("b", "=", 1)),
+		arr("f3", arr("succ", "=", true), arr("c", "=", 1)),
+	), WithEngine(engine), Name("g"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	data := make(map[string]interface{})
+	succ, err := RunE(ctx, f, data)
+	assert.False(t, succ)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, map[string]interface{}{"a": 1}, data, "f2 executor is not applied, f3 is not run")
+
+	// canceled already
+	data = make(map[string]interface{})
+	succ, err = RunE(ctx, f, data)
+	assert.False(t, succ)
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, data)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	result := RunWithResult(ctx, f, make(map[string]interface{}))
+	assert.False(t, result.Succ)
+	assert.True(t, result.Interrupted)
+	require.Len(t, result.Filters, 2)
+	assert.False(t, result.Filters[0].Interrupted)
+	assert.True(t, result.Filters[1].Interrupted)
+
+	// not interrupted
+	succ, err = RunE(context.Background(), f.(*FilterGroup).filters[0], data)
+	assert.True(t, succ)
+	assert.NoError(t, err)
+}
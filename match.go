@@ -0,0 +1,32 @@
+package filter
+
+import (
+	"context"
+)
+
+const matcherCtxKey ctxKey = "matcher"
+
+// matcher collects names of matching filters
+type matcher struct {
+	names []string
+}
+
+func getMatcher(ctx context.Context) *matcher {
+	if m := ctx.Value(matcherCtxKey); m != nil {
+		return m.(*matcher)
+	}
+
+	return nil
+}
+
+// MatchAll return names of all single filters whose conditions succeed, in evaluation order.
+// Executors are not run so data is not changed, filter group doesn't stop for ShortMode or TopK.
+func MatchAll(ctx context.Context, f Filter, data interface{}) []string {
+	m := &matcher{
+		names: make([]string, 0),
+	}
+
+	f.Run(context.WithValue(ctx, matcherCtxKey, m), data)
+
+	return m.names
+}
@@ -0,0 +1,63 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopKAndMatchAll(t *testing.T) {
+	g := NewFilterGroup(Name("banners"), EnableRank(true), TopK(3))
+	for i := 1; i <= 5; i++ {
+		f, err := New(arr(
+			fmt.Sprintf("b%d", i),
+			arr(fmt.Sprintf("data.hide_b%d", i), "!=", true),
+			arr(fmt.Sprintf("b%d", i), "=", true),
+		), NamePrefix("banners."))
+		require.NoError(t, err)
+		// b5 has the highest priority
+		g.Add(f, Weight(1), Priority(i))
+	}
+
+	data := map[string]interface{}{"hide_b4": true}
+	result := RunWithResult(context.Background(), g, data)
+	assert.True(t, result.Succ)
+	assert.Equal(t, map[string]interface{}{
+		"hide_b4": true,
+		"b5":      true,
+		"b3":      true,
+		"b2":      true,
+	}, data, "up to 3 filters by rank")
+	assert.Equal(t, []string{"banners.b5", "banners.b3", "banners.b2"}, result.Fired)
+	assert.Len(t, result.Filters, 4)
+
+	data = map[string]interface{}{"hide_b4": true}
+	assert.Equal(
+		t,
+		[]string{"banners.b5", "banners.b3", "banners.b2", "banners.b1"},
+		MatchAll(context.Background(), g, data),
+	)
+	assert.Equal(t, map[string]interface{}{"hide_b4": true}, data, "executors are not run")
+
+	// ShortMode is TopK(1)
+	g.topK = 0
+	data = map[string]interface{}{}
+	result = RunWithResult(context.Background(), g, data)
+	assert.Equal(t, []string{"banners.b5"}, result.Fired)
+
+	// nested group
+	nested, err := New(arr(
+		arr("f1", arr("succ", "=", true), arr("a", "=", 1)),
+		arr("f2", arr("succ", "=", false), arr("b", "=", 1)),
+	), Name("nested"))
+	require.NoError(t, err)
+	outer := NewFilterGroup(Name("outer"))
+	outer.Add(nested)
+	outer.Add(g)
+	result = RunWithResult(context.Background(), outer, map[string]interface{}{})
+	assert.Equal(t, []string{"nested.f1", "banners.b5"}, result.Fired)
+	assert.Equal(t, []string{"nested.f1"}, result.Filters[0].Fired)
+}
@@ -0,0 +1,94 @@
+package filter
+
+import (
+	"context"
+
+	"github.com/techxmind/filter/core"
+)
+
+type ctxKey string
+
+const resultCtxKey ctxKey = "result"
+
+// Result is structured explanation of a filter run.
+//   single filter: Condition is evaluation detail of conditions, Executors are executors ran when filter succeeded.
+//   filter group : Rank is filter names in rank order if rank is enabled, Filters are results of filters tried in order,
+//                  Fired is names of single filters that succeeded in order, including those of nested groups.
+type Result struct {
+	Name      string                 `json:"name"`
+	Succ      bool                   `json:"succ"`
+	Rank      []string               `json:"rank,omitempty"`
+	Fired     []string               `json:"fired,omitempty"` // names of filters in the group that succeeded, in order
+	Condition *core.ConditionResult  `json:"condition,omitempty"`
+	Executors []*core.ExecutorResult `json:"executors,omitempty"`
+	Filters   []*Result              `json:"filters,omitempty"`
+	Errors    []*core.EvalError      `json:"errors,omitempty"` // errors of variables and operations during the run
+
+	// Interrupted is true if the run is cut short by cancellation or deadline of context
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// RunWithResult run filter, return a structured explanation of the run.
+// Result.Succ is the return value of Filter.Run.
+// Errors are collected with policy ERROR_AS_FALSE, unless ctx contains ErrorCollector already.
+func RunWithResult(ctx context.Context, f Filter, data interface{}) *Result {
+	if core.WithContext(ctx).ErrorCollector() == nil {
+		ctx = core.WithContext(ctx, core.WithErrorCollector(core.NewErrorCollector(core.ERROR_AS_FALSE)))
+	}
+
+	recorder := &resultRecorder{}
+	ctx = context.WithValue(ctx, resultCtxKey, recorder)
+	ctx = context.WithValue(ctx, interruptionCtxKey, &interruption{})
+
+	succ := f.Run(ctx, data)
+
+	// custom filter that doesn't record result
+	if recorder.root == nil {
+		return &Result{Name: f.Name(), Succ: succ, Errors: core.WithContext(ctx).ErrorCollector().Errors()}
+	}
+
+	return recorder.root
+}
+
+// resultRecorder records results of nested filters
+type resultRecorder struct {
+	root  *Result
+	stack []*Result
+}
+
+func getResultRecorder(ctx context.Context) *resultRecorder {
+	if r := ctx.Value(resultCtxKey); r != nil {
+		return r.(*resultRecorder)
+	}
+
+	return nil
+}
+
+func (r *resultRecorder) enter(name string) *Result {
+	result := &Result{Name: name}
+
+	if n := len(r.stack); n > 0 {
+		parent := r.stack[n-1]
+		parent.Filters = append(parent.Filters, result)
+	} else {
+		r.root = result
+	}
+	r.stack = append(r.stack, result)
+
+	return result
+}
+
+func (r *resultRecorder) leave() {
+	if n := len(r.stack); n > 0 {
+		r.stack = r.stack[:n-1]
+	}
+}
+
+// fire record the succeeded single filter in results of enclosing groups
+func (r *resultRecorder) fire(name string) {
+	if n := len(r.stack); n > 1 {
+		for _, result := range r.stack[:n-1] {
+			result.Fired = append(result.Fired, name)
+		}
+	}
+}
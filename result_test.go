@@ -0,0 +1,149 @@
+package filter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/techxmind/filter/core"
+)
+
+func TestRunWithResult(t *testing.T) {
+	ctx := core.NewContext()
+	ctx.Set("level", 3)
+
+	f, err := New(arr(
+		arr(
+			"f1",
+			arr("ctx.level", ">", 5),
+			arr("a", "=", 1),
+		),
+		arr(
+			"f2",
+			arr("any?", "=>", arr(
+				arr("ctx.level", "=", 3),
+				arr("ctx.level", "=", 4),
+			)),
+			arr(
+				arr("a", "=", 2),
+				arr("b", "=", 2),
+			),
+		),
+		arr(
+			"f3",
+			arr("succ", "=", true),
+			arr("a", "=", 3),
+		),
+	), Name("g"), ShortMode(true))
+	require.NoError(t, err)
+
+	data := make(map[string]interface{})
+	result := RunWithResult(ctx, f, data)
+
+	assert.True(t, result.Succ)
+	assert.Equal(t, "g", result.Name)
+	require.Equal(t, 2, len(result.Filters), "f3 is not tried in short mode")
+
+	r1 := result.Filters[0]
+	assert.Equal(t, "g.f1", r1.Name)
+	assert.False(t, r1.Succ)
+	assert.Empty(t, r1.Executors)
+	require.NotNil(t, r1.Condition)
+	require.Equal(t, 1, len(r1.Condition.Conditions))
+	assert.Equal(t, &core.ConditionResult{
+		Expr:      "ctx.level > 5",
+		Variable:  "ctx.level",
+		Value:     3,
+		Operation: ">",
+		Operand:   5,
+		Succ:      false,
+	}, r1.Condition.Conditions[0])
+
+	r2 := result.Filters[1]
+	assert.True(t, r2.Succ)
+	anyResult := r2.Condition.Conditions[0]
+	assert.Equal(t, "any?", anyResult.Logic)
+	assert.True(t, anyResult.Succ)
+	assert.Equal(t, 1, len(anyResult.Conditions), "short-circuit")
+	require.Equal(t, 2, len(r2.Executors))
+	assert.Equal(t, "a = 2", r2.Executors[0].Expr)
+	assert.Equal(t, "b", r2.Executors[1].Key)
+	assert.Equal(t, 2, data["a"])
+
+	f1, _ := New(arr("f1", arr("succ", "=", true), arr("a", "=", 1)))
+	f2, _ := New(arr("f2", arr("succ", "=", true), arr("a", "=", 2)))
+	g := NewFilterGroup(EnableRank(true), Name("rank"))
+	g.Add(f1, Weight(1), Priority(1))
+	g.Add(f2, Weight(1), Priority(2))
+	result = RunWithResult(ctx, g, data)
+	assert.Equal(t, []string{"f2", "f1"}, result.Rank)
+	require.Equal(t, 1, len(result.Filters))
+	assert.Equal(t, "f2", result.Filters[0].Name)
+
+	result = RunWithResult(ctx, f1, data)
+	assert.Equal(t, "f1", result.Name)
+	assert.True(t, result.Succ)
+}
+
+type brokenValue struct{}
+
+func (v brokenValue) Value(_ *core.Context) interface{} { return nil }
+func (v brokenValue) ValueE(_ *core.Context) (interface{}, error) {
+	return nil, errors.New("lookup err")
+}
+
+func TestRunWithErrorPolicy(t *testing.T) {
+	engine := core.NewEngine(core.InheritDefault())
+	engine.VariableFactory().Register(
+		core.SingletonVariableCreator(core.NewSimpleVariable("broken", core.Cacheable, brokenValue{})),
+		"broken",
+	)
+
+	f, err := New(arr(
+		arr(
+			"f1",
+			arr("any?", "=>", arr(
+				arr("broken", "=", "x"),
+				arr("succ", "=", true),
+			)),
+			arr("a", "=", 1),
+		),
+		arr(
+			"f2",
+			arr("succ", "=", true),
+			arr("b", "=", 1),
+		),
+	), WithEngine(engine), Name("g"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		policy   core.ERROR_POLICY
+		expected bool
+		data     map[string]interface{}
+	}{
+		{core.ERROR_AS_FALSE, true, map[string]interface{}{"a": 1, "b": 1}},
+		{core.ERROR_FAIL_FILTER, true, map[string]interface{}{"b": 1}},
+		{core.ERROR_ABORT_GROUP, false, map[string]interface{}{}},
+	}
+
+	for i, c := range tests {
+		collector := core.NewErrorCollector(c.policy)
+		ctx := core.WithContext(context.Background(), core.WithErrorCollector(collector))
+		data := make(map[string]interface{})
+		assert.Equal(t, c.expected, f.Run(ctx, data), "case %d", i)
+		assert.Equal(t, c.data, data, "case %d", i)
+		assert.Equal(t, 1, collector.Len(), "case %d", i)
+	}
+
+	// errors are collected with ERROR_AS_FALSE by default
+	result := RunWithResult(context.Background(), f, make(map[string]interface{}))
+	assert.True(t, result.Succ)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "broken", result.Errors[0].Variable)
+	require.Len(t, result.Filters, 2)
+	assert.Len(t, result.Filters[0].Errors, 1)
+	assert.Empty(t, result.Filters[1].Errors)
+}
@@ -18,6 +18,10 @@ type Weighter interface {
 }
 
 func PickIndexByWeight(items []Weighter, totalWeight int64) int {
+	return pickIndexByWeight(rand.Int63n, items, totalWeight)
+}
+
+func pickIndexByWeight(int63n func(int64) int64, items []Weighter, totalWeight int64) int {
 	if totalWeight == 0 {
 		for _, item := range items {
 			totalWeight += item.Weight()
@@ -28,7 +32,7 @@ func PickIndexByWeight(items []Weighter, totalWeight int64) int {
 		return 0
 	}
 
-	choose := rand.Int63n(totalWeight) + 1
+	choose := int63n(totalWeight) + 1
 	line := int64(0)
 
 	for i, b := range items {